@@ -0,0 +1,174 @@
+package core
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestCreateApiKeyExpiryNotShared garante que uma chave criada sem expiresAt
+// não herda a validade da chave criada antes dela.
+func TestCreateApiKeyExpiryNotShared(t *testing.T) {
+	a := newTestApp(t)
+	access, _ := a.login(testSuperUser, testSuperPass)
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	status, data, raw := a.do("POST", "/auth/api-keys", access, map[string]any{
+		"name":        "expiring",
+		"permissions": []string{string(PermissionViewUser)},
+		"expiresAt":   expiresAt,
+	})
+	if status != fiber.StatusCreated || data["expiresAt"] == nil {
+		t.Fatalf("first key: %d %s", status, raw)
+	}
+	status, data, raw = a.do("POST", "/auth/api-keys", access, map[string]any{
+		"name":        "forever",
+		"permissions": []string{string(PermissionViewUser)},
+	})
+	if status != fiber.StatusCreated {
+		t.Fatalf("second key: %d %s", status, raw)
+	}
+	if data["expiresAt"] != nil {
+		t.Fatalf("second key inherited expiry: %s", raw)
+	}
+	var apiKey ApiKey
+	a.r.GormStore.Where("name = ?", "forever").First(&apiKey)
+	if apiKey.ExpiresAt != nil {
+		t.Fatalf("second key stored with expiry %v", apiKey.ExpiresAt)
+	}
+}
+
+// TestApiKeyAuth cobre o uso da chave em rotas protegidas.
+func TestApiKeyAuth(t *testing.T) {
+	a := newTestApp(t)
+	service := a.r.Controller.Service
+	admin := a.superUser()
+	newKey := func(name string, codes ...PermissionCode) (*ApiKey, string) {
+		req := &CreateApiKey{Name: name}
+		for _, code := range codes {
+			a.permission(code)
+			req.Permissions = append(req.Permissions, string(code))
+		}
+		apiKey, key, err := service.CreateApiKey(admin.ID, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return apiKey, key
+	}
+
+	a.createTenant("Acme", admin)
+	_, valid := newKey("valid", PermissionViewTenant)
+	_, narrow := newKey("narrow", PermissionViewRole)
+	expiredKey, expired := newKey("expired", PermissionViewTenant)
+	a.r.GormStore.Model(expiredKey).Update("expires_at", time.Now().Add(-time.Minute))
+	revokedKey, revoked := newKey("revoked", PermissionViewTenant)
+	if err := service.RevokeApiKey(admin.ID, revokedKey.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		path   string
+		key    string
+		status int
+	}{
+		{"valid key", "/tenants/?page=1&limit=10", valid, fiber.StatusOK},
+		{"missing permission", "/tenants/?page=1&limit=10", narrow, fiber.StatusUnauthorized},
+		{"expired key", "/tenants/?page=1&limit=10", expired, fiber.StatusUnauthorized},
+		{"revoked key", "/tenants/?page=1&limit=10", revoked, fiber.StatusUnauthorized},
+		{"malformed key", "/tenants/?page=1&limit=10", "gk_nope", fiber.StatusUnauthorized},
+		{"account route", "/auth/api-keys", valid, fiber.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			req.Header.Set(fiber.HeaderAuthorization, "ApiKey "+tc.key)
+			res, err := a.app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tc.status {
+				body, _ := io.ReadAll(res.Body)
+				t.Fatalf("status %d, want %d: %s", res.StatusCode, tc.status, body)
+			}
+		})
+	}
+}
+
+// TestApiKeyTenantIsolation garante que a chave fica presa ao tenant de quem
+// a criou e não enxerga os dados de outros tenants.
+func TestApiKeyTenantIsolation(t *testing.T) {
+	a := newTestApp(t)
+	acme := a.createTenant("Acme")
+	globex := a.createTenant("Globex")
+	carol, carolToken := a.tenantAdmin(acme, "carol", PermissionViewRole)
+	a.addMember(globex, a.createUser("bob"))
+
+	status, data, raw := a.do("POST", "/auth/api-keys", carolToken, map[string]any{
+		"name":        "scoped",
+		"permissions": []string{string(PermissionViewRole)},
+	})
+	if status != fiber.StatusCreated {
+		t.Fatalf("create key: %d %s", status, raw)
+	}
+	scoped := data["key"].(string)
+	// Membro de tenant com role global: a chave criada fora do tenant não é global
+	dave := a.createUser("dave", a.createRole("viewer", PermissionViewRole))
+	a.addMember(acme, dave)
+	_, global, err := a.r.Controller.Service.CreateApiKey(dave.ID, &CreateApiKey{
+		Name:        "global",
+		Permissions: []string{string(PermissionViewRole)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := func(key string) (int, string) {
+		req := httptest.NewRequest("GET", "/users/?page=1&limit=10", nil)
+		req.Header.Set(fiber.HeaderAuthorization, "ApiKey "+key)
+		res, err := a.app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+
+	cases := []struct {
+		name    string
+		key     string
+		status  int
+		visible []string
+		hidden  []string
+	}{
+		{"key of the tenant", scoped, fiber.StatusOK, []string{`"username":"carol"`}, []string{`"username":"bob"`, `"username":"admin"`}},
+		{"key without tenant", global, fiber.StatusForbidden, nil, []string{`"username":"bob"`, `"username":"admin"`}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, body := users(tc.key)
+			if status != tc.status {
+				t.Fatalf("status %d, want %d: %s", status, tc.status, body)
+			}
+			for _, want := range tc.visible {
+				if !strings.Contains(body, want) {
+					t.Fatalf("missing %s: %s", want, body)
+				}
+			}
+			for _, hidden := range tc.hidden {
+				if strings.Contains(body, hidden) {
+					t.Fatalf("leaked %s: %s", hidden, body)
+				}
+			}
+		})
+	}
+
+	// Fora do tenant, a chave deixa de valer
+	a.r.GormStore.Model(&Membership{}).Where("user_id = ? AND tenant_id = ?", carol.ID, acme.ID).Update("active", false)
+	if status, body := users(scoped); status != fiber.StatusUnauthorized {
+		t.Fatalf("key outlived the membership: %d %s", status, body)
+	}
+}
@@ -1,8 +1,10 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -19,51 +21,436 @@ func (con *Controller) HealthHandler(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusOK).JSON(health)
 }
 
+func (con *Controller) JwksHandler(ctx *fiber.Ctx) error {
+	jwks, err := con.Jwt.JWKS()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return ctx.Status(fiber.StatusOK).JSON(jwks)
+}
+
 func (con *Controller) LoginHandler(ctx *fiber.Ctx) error {
 	req := ctx.Locals("validatedData").(*Login)
 
 	// find username or email in database
-	user, err := con.Service.Login(req)
+	user, err := con.Service.Login(req, clientInfo(ctx))
 	if err != nil {
+		var lockout *LockoutError
+		if errors.As(err, &lockout) {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(lockout.RetryAfter().Seconds())+1))
+			return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+		}
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
-	permissions := ExtractCodePermissionsByUser(user)
+	return con.firstFactorLogin(ctx, user)
+}
+
+func (con *Controller) LoginMfaHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*LoginMfa)
+
+	user, err := con.Service.LoginMfa(req, clientInfo(ctx))
+	if err != nil {
+		var lockout *LockoutError
+		if errors.As(err, &lockout) {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(lockout.RetryAfter().Seconds())+1))
+			return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+		}
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	return con.completeLogin(ctx, user)
+}
+
+// firstFactorLogin conclui um login de primeiro fator (senha ou OIDC): com MFA
+// habilitado, os tokens só são emitidos após /auth/login/mfa.
+func (con *Controller) firstFactorLogin(ctx *fiber.Ctx, user *User) error {
+	if user.MfaEnabled {
+		challenge, err := con.Service.MfaChallenge(user)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return ctx.Status(fiber.StatusOK).JSON(challenge)
+	}
+
+	return con.completeLogin(ctx, user)
+}
+
+// completeLogin inicia a sessão ou, se a senha estiver expirada ou for
+// provisória, responde com o token restrito para a troca.
+func (con *Controller) completeLogin(ctx *fiber.Ctx, user *User) error {
+	if reason := con.Service.PasswordChangeReason(user); reason != "" {
+		challenge, err := con.Service.PasswordChangeChallenge(user, reason)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		return ctx.Status(fiber.StatusOK).JSON(challenge)
+	}
 
 	// generate tokens
-	accessToken, err := GenerateToken(&GenToken{
-		Id:          user.ID,
-		AppName:     con.Jwt.AppName,
-		Permissions: permissions,
-		IsSuperUser: user.IsSuperUser,
-		TimeZone:    con.Jwt.TimeZone,
-		JwtSecret:   con.Jwt.JwtSecret,
-		Ttl:         con.Jwt.JwtExpireAccess,
-	})
+	res, err := con.Service.StartSession(user, clientInfo(ctx))
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
-	refreshToken, err := GenerateToken(&GenToken{
-		Id:          user.ID,
-		AppName:     con.Jwt.AppName,
-		Permissions: permissions,
-		IsSuperUser: user.IsSuperUser,
-		TimeZone:    con.Jwt.TimeZone,
-		JwtSecret:   con.Jwt.JwtSecret,
-		Ttl:         con.Jwt.JwtExpireRefresh,
-	})
+
+	// send response
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
+func (con *Controller) EnrollMfaHandler(ctx *fiber.Ctx) error {
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	res, err := con.Service.EnrollMfa(claims.Sub)
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
 
-	// send response
-	res := &Token{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+func (con *Controller) ConfirmMfaHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*MfaCode)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	res, err := con.Service.ConfirmMfa(claims.Sub, req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
+func (con *Controller) DisableMfaHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*MfaCode)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if err := con.Service.DisableMfa(claims.Sub, req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) ForgotPasswordHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*ForgotPassword)
+
+	if err := con.Service.ForgotPassword(ctx.Context(), req); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusAccepted)
+}
+
+func (con *Controller) ResetPasswordHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*ResetPassword)
+
+	if err := con.Service.ResetPassword(req); err != nil {
+		return passwordError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) ChangeExpiredPasswordHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*ChangeExpiredPassword)
+
+	user, err := con.Service.ChangeExpiredPassword(req)
+	if err != nil {
+		return passwordError(ctx, err)
+	}
+
+	res, err := con.Service.StartSession(user, clientInfo(ctx))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 	return ctx.Status(fiber.StatusOK).JSON(res)
 }
 
+func (con *Controller) ChangePasswordHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*ChangePassword)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if err := con.Service.ChangePassword(claims, req); err != nil {
+		return passwordError(ctx, err)
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) OidcLoginHandler(ctx *fiber.Ctx) error {
+	authURL, err := con.Service.OidcAuthURL(ctx.Context(), ctx.Params("provider"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.Redirect(authURL, fiber.StatusFound)
+}
+
+func (con *Controller) OidcCallbackHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*OidcCallback)
+
+	user, err := con.Service.OidcLogin(ctx.Context(), ctx.Params("provider"), req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	// O provedor substitui apenas a senha; o segundo fator continua exigido
+	return con.firstFactorLogin(ctx, user)
+}
+
+func (con *Controller) RefreshHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*Refresh)
+
+	res, err := con.Service.RefreshTokens(req, clientInfo(ctx))
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
+func (con *Controller) LogoutHandler(ctx *fiber.Ctx) error {
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	// O corpo é opcional: sem refresh token apenas o access token é revogado
+	req := &Logout{}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid body: %s", err.Error()))
+		}
+	}
+
+	if err := con.Service.Logout(claims, req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) RevokeUserTokensHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*UserParam)
+
+	editor, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if err := con.Service.RevokeUserTokens(editor.Sub, req.ID); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) UnlockUserHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*UserParam)
+
+	editor, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if err := con.Service.UnlockUser(editor.Sub, req.ID); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) ImpersonateHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*UserParam)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	res, err := con.Service.Impersonate(claims, req.ID, clientInfo(ctx))
+	if err != nil {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
+func (con *Controller) MeHandler(ctx *fiber.Ctx) error {
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	user, err := con.Service.ForTenant(claims.Tid).Me(claims.Sub)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	res := &MeSchema{
+		UserSchema: UserSchema{
+			ID:          user.ID,
+			FirstName:   user.FirstName,
+			LastName:    user.LastName,
+			Username:    user.Username,
+			Email:       user.Email,
+			Active:      &user.Active,
+			IsSuperUser: user.IsSuperUser,
+			Phone1:      user.Phone1,
+			Phone2:      user.Phone2,
+			Roles:       ExtractNameRolesByUser(*user),
+		},
+		TenantID:    claims.Tid,
+		Permissions: ExtractCodePermissionsByUser(user),
+		Denies:      ExtractDeniedCodesByUser(user),
+		RoleNames:   ExtractRoleNamesByUser(user),
+	}
+	if res.Permissions == nil {
+		res.Permissions = []string{}
+	}
+	if res.Denies == nil {
+		res.Denies = []string{}
+	}
+	if res.RoleNames == nil {
+		res.RoleNames = []string{}
+	}
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
+func (con *Controller) ListMySessionsHandler(ctx *fiber.Ctx) error {
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	sessions, err := con.Service.ListSessions(claims.Sub, claims.Sub)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.Status(fiber.StatusOK).JSON(sessionSchemas(sessions, claims.Sid))
+}
+
+func (con *Controller) RevokeMySessionHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*SessionParam)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if err := con.Service.RevokeUserSession(claims.Sub, claims.Sub, req.ID); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) RevokeMySessionsHandler(ctx *fiber.Ctx) error {
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if err := con.Service.RevokeUserSessions(claims.Sub, claims.Sub); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) ListUserSessionsHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*UserParam)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	sessions, err := con.Service.ListSessions(claims.Sub, req.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.Status(fiber.StatusOK).JSON(sessionSchemas(sessions, claims.Sid))
+}
+
+func (con *Controller) RevokeUserSessionHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*UserSessionParam)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if err := con.Service.RevokeUserSession(claims.Sub, req.ID, req.SessionID); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) RevokeUserSessionsHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*UserParam)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if err := con.Service.RevokeUserSessions(claims.Sub, req.ID); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) CreateApiKeyHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*CreateApiKey)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	apiKey, key, err := con.Service.ForTenant(claims.Tid).CreateApiKey(claims.Sub, req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	res := apiKeySchema(apiKey)
+	res.Key = key
+	return ctx.Status(fiber.StatusCreated).JSON(res)
+}
+
+func (con *Controller) ListApiKeysHandler(ctx *fiber.Ctx) error {
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	apiKeys, err := con.Service.ListApiKeys(claims.Sub)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	data := []ApiKeySchema{}
+	for i := range apiKeys {
+		data = append(data, apiKeySchema(&apiKeys[i]))
+	}
+	return ctx.Status(fiber.StatusOK).JSON(data)
+}
+
+func (con *Controller) RevokeApiKeyHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*ApiKeyParam)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if err := con.Service.RevokeApiKey(claims.Sub, req.ID); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
 func (con *Controller) ListPermissiontHandler(ctx *fiber.Ctx) error {
 	req := ctx.Locals("validatedData").(*Paginate)
 
@@ -102,7 +489,11 @@ func (con *Controller) ListRoleHandler(ctx *fiber.Ctx) error {
 	req := ctx.Locals("validatedData").(*Paginate)
 
 	var roles []Role
-	if err := con.Service.ListRole(&roles); err != nil {
+	service, err := con.tenantService(ctx)
+	if err != nil {
+		return err
+	}
+	if err := service.ListRole(&roles); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
@@ -118,6 +509,7 @@ func (con *Controller) ListRoleHandler(ctx *fiber.Ctx) error {
 			ID:          role.ID,
 			Name:        role.Name,
 			Description: role.Description,
+			Parents:     roleIDs(role.Parents),
 		}
 		for _, permission := range role.Permissions {
 			schema.Permissions = append(schema.Permissions, PermissionSchema{
@@ -142,8 +534,17 @@ func (con *Controller) ListRoleHandler(ctx *fiber.Ctx) error {
 func (con *Controller) CreateRoleHandler(ctx *fiber.Ctx) error {
 	req := ctx.Locals("validatedData").(*CreateRole)
 
+	creator, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
 	var role Role
-	if err := con.Service.CreateRole(&role, req); err != nil {
+	service, err := con.tenantService(ctx)
+	if err != nil {
+		return err
+	}
+	if err := service.CreateRole(creator, &role, req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
@@ -164,14 +565,61 @@ func (con *Controller) CreateRoleHandler(ctx *fiber.Ctx) error {
 		ID:          role.ID,
 		Name:        role.Name,
 		Permissions: permissionCodes, // Adicionar apenas os codes
+		Parents:     roleIDs(role.Parents),
 	}
 	return ctx.Status(fiber.StatusCreated).JSON(res)
 }
 
+func (con *Controller) UpdateRoleHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*CreateRole)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid role id")
+	}
+
+	editor, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	service, err := con.tenantService(ctx)
+	if err != nil {
+		return err
+	}
+	role, err := service.UpdateRole(editor, uint(id), req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	var permissionCodes []PermissionSchema
+	for _, permission := range role.Permissions {
+		permissionCodes = append(permissionCodes, PermissionSchema{
+			ID:          permission.ID,
+			Code:        permission.Code,
+			Name:        permission.Name,
+			Description: permission.Description,
+		})
+	}
+
+	res := &RoleSchema{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissionCodes,
+		Parents:     roleIDs(role.Parents),
+	}
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
 func (con *Controller) ListUserHandler(ctx *fiber.Ctx) error {
 	req := ctx.Locals("validatedData").(*Paginate)
 
-	users, err := con.Service.ListUser()
+	service, err := con.tenantService(ctx)
+	if err != nil {
+		return err
+	}
+	users, err := service.ListUser()
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
@@ -190,7 +638,7 @@ func (con *Controller) ListUserHandler(ctx *fiber.Ctx) error {
 			LastName:    user.LastName,
 			Username:    user.Username,
 			Email:       user.Email,
-			Active:      user.Active,
+			Active:      &user.Active,
 			IsSuperUser: user.IsSuperUser,
 			Phone1:      user.Phone1,
 			Phone2:      user.Phone2,
@@ -211,21 +659,30 @@ func (con *Controller) ListUserHandler(ctx *fiber.Ctx) error {
 func (con *Controller) CreateUserHandler(ctx *fiber.Ctx) error {
 	req := ctx.Locals("validatedData").(*CreateUser)
 
-	if err := ValidatePassword(req.Password); err != nil {
-		return ctx.Status(fiber.StatusBadRequest).JSON(err)
+	if err := con.ValidatePassword(req.Password, &User{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Username:  req.Username,
+		Email:     req.Email,
+	}); err != nil {
+		return passwordError(ctx, err)
 	}
-	hashedPassword, err := HashPassword(req.Password)
+	hashedPassword, err := con.HashPassword(req.Password)
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("crypting password failed: %s", err.Error()))
 	}
 	req.Password = hashedPassword
 
-	creator, err := GetJwtHeaderPayload(ctx.Get("Authorization"), con.Jwt.JwtSecret)
+	creator, err := GetClaims(ctx)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
 	}
 
-	user, err := con.Service.CreateUser(creator.Claims.Sub, req)
+	service, err := con.tenantService(ctx)
+	if err != nil {
+		return err
+	}
+	user, err := service.CreateUser(creator.Sub, req)
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
@@ -235,7 +692,7 @@ func (con *Controller) CreateUserHandler(ctx *fiber.Ctx) error {
 		LastName:    user.LastName,
 		Username:    user.Username,
 		Email:       user.Email,
-		Active:      user.Active,
+		Active:      &user.Active,
 		IsSuperUser: user.IsSuperUser,
 		Phone1:      user.Phone1,
 		Phone2:      user.Phone2,
@@ -247,14 +704,23 @@ func (con *Controller) CreateUserHandler(ctx *fiber.Ctx) error {
 func (con *Controller) UpdateUserHandler(ctx *fiber.Ctx) error {
 	req := ctx.Locals("validatedData").(*UserSchema)
 
-	fmt.Println(req.ID)
+	// O id do caminho prevalece sobre o do corpo, já validado por RequirePolicy
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user id")
+	}
+	req.ID = uint(id)
 
-	editor, err := GetJwtHeaderPayload(ctx.Get("Authorization"), con.Jwt.JwtSecret)
+	editor, err := GetClaims(ctx)
 	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
 	}
 
-	user, err := con.Service.UpdateUser(editor.Claims.Sub, uint(req.ID), req)
+	service, err := con.tenantService(ctx)
+	if err != nil {
+		return err
+	}
+	user, err := service.UpdateUser(editor.Sub, req.ID, req)
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
@@ -265,7 +731,7 @@ func (con *Controller) UpdateUserHandler(ctx *fiber.Ctx) error {
 		LastName:    user.LastName,
 		Username:    user.Username,
 		Email:       user.Email,
-		Active:      user.Active,
+		Active:      &user.Active,
 		IsSuperUser: user.IsSuperUser,
 		Phone1:      user.Phone1,
 		Phone2:      user.Phone2,
@@ -273,3 +739,239 @@ func (con *Controller) UpdateUserHandler(ctx *fiber.Ctx) error {
 	}
 	return ctx.Status(fiber.StatusOK).JSON(res)
 }
+
+func (con *Controller) ListUserPermissionsHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*UserParam)
+
+	service, err := con.tenantService(ctx)
+	if err != nil {
+		return err
+	}
+	overrides, err := service.ListUserPermissions(req.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.Status(fiber.StatusOK).JSON(userPermissionSchemas(overrides))
+}
+
+func (con *Controller) SetUserPermissionHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*SetUserPermission)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid user id")
+	}
+
+	editor, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	service, err := con.tenantService(ctx)
+	if err != nil {
+		return err
+	}
+	override, err := service.SetUserPermission(editor, uint(id), req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.Status(fiber.StatusOK).JSON(userPermissionSchemas([]UserPermission{*override})[0])
+}
+
+func (con *Controller) DeleteUserPermissionHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*UserPermissionParam)
+
+	editor, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	service, err := con.tenantService(ctx)
+	if err != nil {
+		return err
+	}
+	if err := service.DeleteUserPermission(editor, req.ID, req.PermissionID); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+func (con *Controller) ListMyTenantsHandler(ctx *fiber.Ctx) error {
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	tenants, err := con.Service.ListMyTenants(claims.Sub)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.Status(fiber.StatusOK).JSON(tenantSchemas(tenants, claims.Tid))
+}
+
+func (con *Controller) SwitchTenantHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*TenantParam)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	res, err := con.Service.SwitchTenant(claims, req.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	return ctx.Status(fiber.StatusOK).JSON(res)
+}
+
+func (con *Controller) ListTenantHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*Paginate)
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	// Fora do superusuário, apenas os tenants dos quais o usuário participa
+	var tenants []Tenant
+	if claims.IsSuperUser {
+		tenants, err = con.Service.ListTenant()
+	} else {
+		tenants, err = con.Service.ListMyTenants(claims.Sub)
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := Pagination(req.Page, req.Limit, &tenants); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.Status(fiber.StatusOK).JSON(tenantSchemas(tenants, claims.Tid))
+}
+
+func (con *Controller) CreateTenantHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*CreateTenant)
+
+	tenant, err := con.Service.CreateTenant(req)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.Status(fiber.StatusCreated).JSON(tenantSchemas([]Tenant{*tenant}, 0)[0])
+}
+
+func (con *Controller) AddMemberHandler(ctx *fiber.Ctx) error {
+	req := ctx.Locals("validatedData").(*AddMember)
+
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid tenant id")
+	}
+
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+
+	if err := con.Service.AddMember(claims, uint(id), req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return ctx.SendStatus(fiber.StatusNoContent)
+}
+
+// tenantService retorna o serviço restrito ao tenant ativo do token.
+func (con *Controller) tenantService(ctx *fiber.Ctx) (*Service, error) {
+	claims, err := GetClaims(ctx)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	service, err := con.Service.ForClaims(claims)
+	if errors.Is(err, ErrTenantRequired) {
+		return nil, fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	return service, nil
+}
+
+// passwordError responde com as violações da política de senha em JSON; os
+// demais erros seguem como fiber.Error.
+func passwordError(ctx *fiber.Ctx, err error) error {
+	var policyErr *PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(policyErr)
+	}
+	return fiber.NewError(fiber.StatusBadRequest, err.Error())
+}
+
+func clientInfo(ctx *fiber.Ctx) ClientInfo {
+	return ClientInfo{
+		IP:        ctx.IP(),
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+	}
+}
+
+func userPermissionSchemas(overrides []UserPermission) []UserPermissionSchema {
+	data := []UserPermissionSchema{}
+	for _, override := range overrides {
+		data = append(data, UserPermissionSchema{
+			PermissionID: override.PermissionID,
+			Code:         override.Permission.Code,
+			Effect:       override.Effect,
+		})
+	}
+	return data
+}
+
+func roleIDs(roles []Role) []uint {
+	ids := []uint{}
+	for _, role := range roles {
+		ids = append(ids, role.ID)
+	}
+	return ids
+}
+
+func tenantSchemas(tenants []Tenant, currentID uint) []TenantSchema {
+	data := []TenantSchema{}
+	for _, tenant := range tenants {
+		data = append(data, TenantSchema{
+			ID:        tenant.ID,
+			Name:      tenant.Name,
+			Active:    tenant.Active,
+			Current:   tenant.ID == currentID,
+			CreatedAt: tenant.CreatedAt,
+		})
+	}
+	return data
+}
+
+func sessionSchemas(sessions []Session, currentID uint) []SessionSchema {
+	data := []SessionSchema{}
+	for _, session := range sessions {
+		data = append(data, SessionSchema{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == currentID,
+		})
+	}
+	return data
+}
+
+func apiKeySchema(apiKey *ApiKey) ApiKeySchema {
+	schema := ApiKeySchema{
+		ID:          apiKey.ID,
+		Name:        apiKey.Name,
+		Prefix:      fmt.Sprintf("%s_%s", ApiKeyPrefix, apiKey.Prefix),
+		Permissions: []string{},
+		CreatedAt:   apiKey.CreatedAt,
+		ExpiresAt:   apiKey.ExpiresAt,
+		LastUsedAt:  apiKey.LastUsedAt,
+	}
+	for _, permission := range apiKey.Permissions {
+		schema.Permissions = append(schema.Permissions, permission.Code)
+	}
+	return schema
+}
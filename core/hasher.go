@@ -0,0 +1,194 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordHasher gera e confere hashes de senha. Os hashes são
+// autodescritivos (prefixo $2a$/$2b$ para bcrypt e formato PHC $argon2id$),
+// de modo que hashes antigos continuam verificáveis após trocar o algoritmo.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) bool
+	// NeedsRehash indica que o hash usa outro algoritmo ou parâmetros
+	// desatualizados e deve ser regerado no próximo login.
+	NeedsRehash(hash string) bool
+}
+
+// DefaultPasswordHasher é usado quando AppConfig.Hasher não é configurado.
+var DefaultPasswordHasher PasswordHasher = BcryptHasher{}
+
+// BcryptMaxPasswordBytes é o maior tamanho de senha aceito pelo bcrypt.
+const BcryptMaxPasswordBytes = 72
+
+// passwordByteLimiter é implementado pelos hashers que limitam o tamanho da
+// senha; a política de senhas respeita esse limite.
+type passwordByteLimiter interface {
+	MaxPasswordBytes() int
+}
+
+// BcryptHasher gera hashes bcrypt. Cost zero usa bcrypt.DefaultCost.
+type BcryptHasher struct {
+	Cost int
+}
+
+// MaxPasswordBytes retorna BcryptMaxPasswordBytes: senhas maiores são
+// rejeitadas pelo bcrypt.
+func (h BcryptHasher) MaxPasswordBytes() int {
+	return BcryptMaxPasswordBytes
+}
+
+func (h BcryptHasher) cost() int {
+	if h.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return h.Cost
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.cost())
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %s", err.Error())
+	}
+	return string(hashedPassword), nil
+}
+
+func (h BcryptHasher) Verify(password, hash string) bool {
+	return CheckPasswordHash(password, hash)
+}
+
+func (h BcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost()
+}
+
+const (
+	DefaultArgon2idTime    = 3
+	DefaultArgon2idMemory  = 64 * 1024 // KiB
+	DefaultArgon2idThreads = 4
+	DefaultArgon2idKeyLen  = 32
+	DefaultArgon2idSaltLen = 16
+)
+
+// Argon2idHasher gera hashes argon2id no formato PHC. Campos zerados usam os
+// valores DefaultArgon2id* (recomendação do RFC 9106).
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+type argon2idParams struct {
+	time, memory uint32
+	threads      uint8
+	salt, key    []byte
+}
+
+func (h Argon2idHasher) params() Argon2idHasher {
+	if h.Time == 0 {
+		h.Time = DefaultArgon2idTime
+	}
+	if h.Memory == 0 {
+		h.Memory = DefaultArgon2idMemory
+	}
+	if h.Threads == 0 {
+		h.Threads = DefaultArgon2idThreads
+	}
+	if h.KeyLen == 0 {
+		h.KeyLen = DefaultArgon2idKeyLen
+	}
+	if h.SaltLen == 0 {
+		h.SaltLen = DefaultArgon2idSaltLen
+	}
+	return h
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	p := h.params()
+	salt := make([]byte, p.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to hash password: %s", err.Error())
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) Verify(password, hash string) bool {
+	return CheckPasswordHash(password, hash)
+}
+
+func (h Argon2idHasher) NeedsRehash(hash string) bool {
+	p := h.params()
+	current, err := parseArgon2idHash(hash)
+	if err != nil {
+		return true
+	}
+	return current.time != p.Time ||
+		current.memory != p.Memory ||
+		current.threads != p.Threads ||
+		uint32(len(current.key)) != p.KeyLen ||
+		uint32(len(current.salt)) != p.SaltLen
+}
+
+func parseArgon2idHash(hash string) (*argon2idParams, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return nil, fmt.Errorf("invalid argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2id version")
+	}
+	params := &argon2idParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, fmt.Errorf("invalid argon2id parameters")
+	}
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("invalid argon2id salt")
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, fmt.Errorf("invalid argon2id key")
+	}
+	return params, nil
+}
+
+func checkArgon2idHash(password, hash string) bool {
+	params, err := parseArgon2idHash(hash)
+	if err != nil {
+		return false
+	}
+	key := argon2.IDKey([]byte(password), params.salt, params.time, params.memory, params.threads, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1
+}
+
+func (a *AppConfig) hasher() PasswordHasher {
+	if a.Hasher != nil {
+		return a.Hasher
+	}
+	return DefaultPasswordHasher
+}
+
+// HashPassword gera o hash com o PasswordHasher configurado.
+func (a *AppConfig) HashPassword(password string) (string, error) {
+	return a.hasher().Hash(password)
+}
+
+// CheckPassword confere a senha com o PasswordHasher configurado.
+func (a *AppConfig) CheckPassword(password, hash string) bool {
+	return a.hasher().Verify(password, hash)
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// testArgon2id usa parâmetros baixos só para acelerar os testes.
+var testArgon2id = Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	cases := []struct {
+		name   string
+		hasher PasswordHasher
+		prefix string
+	}{
+		{"bcrypt", BcryptHasher{Cost: 4}, "$2a$04$"},
+		{"argon2id", testArgon2id, "$argon2id$v=19$m=1024,t=1,p=1$"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			hash, err := tc.hasher.Hash(testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(hash, tc.prefix) {
+				t.Fatalf("hash %s, want prefix %s", hash, tc.prefix)
+			}
+			if !tc.hasher.Verify(testPassword, hash) {
+				t.Fatal("correct password rejected")
+			}
+			if tc.hasher.Verify("Wrong@123", hash) {
+				t.Fatal("wrong password accepted")
+			}
+			if tc.hasher.NeedsRehash(hash) {
+				t.Fatal("fresh hash needs rehash")
+			}
+			if other, _ := tc.hasher.Hash(testPassword); other == hash {
+				t.Fatal("hash without random salt")
+			}
+		})
+	}
+}
+
+// TestNeedsRehash cobre a troca de algoritmo e de parâmetros.
+func TestNeedsRehash(t *testing.T) {
+	hash := func(hasher PasswordHasher) string {
+		h, err := hasher.Hash(testPassword)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	bcrypt4 := hash(BcryptHasher{Cost: 4})
+	argon := hash(testArgon2id)
+
+	cases := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{"bcrypt same cost", BcryptHasher{Cost: 4}, bcrypt4, false},
+		{"bcrypt higher cost", BcryptHasher{Cost: 5}, bcrypt4, true},
+		{"bcrypt from argon2id", BcryptHasher{Cost: 4}, argon, true},
+		{"argon2id same parameters", testArgon2id, argon, false},
+		{"argon2id more time", Argon2idHasher{Time: 2, Memory: 1024, Threads: 1}, argon, true},
+		{"argon2id more memory", Argon2idHasher{Time: 1, Memory: 2048, Threads: 1}, argon, true},
+		{"argon2id more threads", Argon2idHasher{Time: 1, Memory: 1024, Threads: 2}, argon, true},
+		{"argon2id longer key", Argon2idHasher{Time: 1, Memory: 1024, Threads: 1, KeyLen: 64}, argon, true},
+		{"argon2id from bcrypt", testArgon2id, bcrypt4, true},
+		{"argon2id malformed", testArgon2id, "$argon2id$v=19$m=1024", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.hasher.NeedsRehash(tc.hash); got != tc.want {
+				t.Fatalf("NeedsRehash = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestLoginRehash garante que o login migra o hash bcrypt para argon2id
+// apenas com a senha correta.
+func TestLoginRehash(t *testing.T) {
+	cases := []struct {
+		name       string
+		password   string
+		wantArgon2 bool
+	}{
+		{"correct password upgrades", testPassword, true},
+		{"wrong password keeps bcrypt", "Wrong@123", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t, func(c *AppConfig) { c.Hasher = testArgon2id })
+			bob := a.createUser("bob")
+			legacy, err := BcryptHasher{Cost: 4}.Hash(testPassword)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.r.GormStore.Model(bob).Update("password", legacy)
+
+			a.do("POST", "/auth/login", "", map[string]any{"username": "bob", "password": tc.password})
+			var user User
+			a.r.GormStore.First(&user, bob.ID)
+			if got := strings.HasPrefix(user.Password, "$argon2id$"); got != tc.wantArgon2 {
+				t.Fatalf("argon2id = %v, want %v (%s)", got, tc.wantArgon2, user.Password)
+			}
+			// A senha continua valendo depois da migração
+			a.login("bob", testPassword)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testSuperUser = "admin"
+	testSuperPass = "Admin@123"
+	testPassword  = "Xyz@12345"
+)
+
+// testApp é uma instância completa do módulo sobre um SQLite em memória,
+// exclusivo do teste.
+type testApp struct {
+	t   *testing.T
+	app *fiber.App
+	r   *Router
+}
+
+func newTestApp(t *testing.T, configure ...func(*AppConfig)) *testApp {
+	t.Helper()
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(
+		sqlite.Open("file:"+name+"?mode=memory&cache=shared&_pragma=foreign_keys(1)"),
+		&gorm.Config{Logger: logger.Default.LogMode(logger.Silent)},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	app := fiber.New()
+	config := &AppConfig{
+		App:       app,
+		GormStore: db,
+		Jwt: AppJwt{
+			AppName:          "test",
+			TimeZone:         "UTC",
+			JwtSecret:        "test-secret",
+			JwtExpireAccess:  time.Minute,
+			JwtExpireRefresh: time.Hour,
+		},
+		Super: &AppSuper{
+			SuperName:  "Admin",
+			SuperUser:  testSuperUser,
+			SuperEmail: "admin@example.com",
+			SuperPass:  testSuperPass,
+			SuperPhone: "+5511999999999",
+		},
+		Hasher: BcryptHasher{Cost: 4},
+	}
+	for _, fn := range configure {
+		fn(config)
+	}
+	router := New(config)
+	router.RegisterRouter(app)
+	return &testApp{t: t, app: app, r: router}
+}
+
+// do executa a requisição e retorna o status, o corpo decodificado e o bruto.
+func (a *testApp) do(method, path, token string, body any) (int, map[string]any, string) {
+	a.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+	res, err := a.app.Test(req, -1)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(res.Body)
+	var data map[string]any
+	_ = json.Unmarshal(raw, &data)
+	return res.StatusCode, data, string(raw)
+}
+
+func (a *testApp) login(username, password string) (string, string) {
+	a.t.Helper()
+	status, data, raw := a.do("POST", "/auth/login", "", map[string]any{"username": username, "password": password})
+	if status != fiber.StatusOK {
+		a.t.Fatalf("login %s: %d %s", username, status, raw)
+	}
+	access, _ := data["access_token"].(string)
+	refresh, _ := data["refresh_token"].(string)
+	return access, refresh
+}
+
+// createUser cria o usuário diretamente no banco, com a senha de teste já
+// definida pelo próprio usuário.
+func (a *testApp) createUser(username string, roles ...Role) *User {
+	a.t.Helper()
+	hash, err := a.r.HashPassword(testPassword)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	now := time.Now()
+	user := &User{
+		FirstName:         username,
+		Username:          username,
+		Email:             username + "@example.com",
+		Password:          hash,
+		Active:            true,
+		Phone1:            "+5511988887777",
+		Roles:             roles,
+		PasswordChangedAt: &now,
+	}
+	if err := a.r.GormStore.Create(user).Error; err != nil {
+		a.t.Fatal(err)
+	}
+	return user
+}
+
+// createRole cria a role com as permissões informadas pelo código.
+func (a *testApp) createRole(name string, codes ...PermissionCode) Role {
+	a.t.Helper()
+	role := Role{Name: name, Active: true}
+	for _, code := range codes {
+		role.Permissions = append(role.Permissions, a.permission(code))
+	}
+	if err := a.r.GormStore.Create(&role).Error; err != nil {
+		a.t.Fatal(err)
+	}
+	return role
+}
+
+func (a *testApp) permission(code PermissionCode) Permission {
+	a.t.Helper()
+	var permission Permission
+	if err := a.r.GormStore.Where(Permission{Code: string(code)}).
+		Attrs(Permission{Name: string(code), Active: true}).
+		FirstOrCreate(&permission).Error; err != nil {
+		a.t.Fatal(err)
+	}
+	return permission
+}
+
+// createTenant cria o tenant com os usuários informados como membros, sem
+// roles no tenant.
+func (a *testApp) createTenant(name string, members ...*User) *Tenant {
+	a.t.Helper()
+	tenant := &Tenant{Name: name, Active: true}
+	if err := a.r.GormStore.Create(tenant).Error; err != nil {
+		a.t.Fatal(err)
+	}
+	for _, member := range members {
+		a.addMember(tenant, member)
+	}
+	return tenant
+}
+
+func (a *testApp) addMember(tenant *Tenant, user *User, roles ...Role) *Membership {
+	a.t.Helper()
+	membership := &Membership{UserID: user.ID, TenantID: tenant.ID, Roles: roles, Active: true}
+	if err := a.r.GormStore.Create(membership).Error; err != nil {
+		a.t.Fatal(err)
+	}
+	return membership
+}
+
+func (a *testApp) superUser() *User {
+	a.t.Helper()
+	var user User
+	if err := a.r.GormStore.Where("username = ?", testSuperUser).First(&user).Error; err != nil {
+		a.t.Fatal(err)
+	}
+	return &user
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DefaultImpersonationTTL é a validade padrão do token de personificação.
+const DefaultImpersonationTTL = 15 * time.Minute
+
+// Impersonate emite um access token curto com as permissões do usuário alvo no
+// tenant ativo do superusuário e o claim "act" identificando o superusuário.
+// Não há refresh token nem sessão.
+func (s *Service) Impersonate(claims *JwtClaims, id uint, client ClientInfo) (*ImpersonationSchema, error) {
+	if claims.Act != nil {
+		return nil, fmt.Errorf("cannot impersonate while impersonating")
+	}
+	actor, err := s.GetUserByID(claims.Sub)
+	if err != nil {
+		return nil, fmt.Errorf("user with id '%v' does not exist", claims.Sub)
+	}
+	if !actor.IsSuperUser {
+		return nil, fmt.Errorf("user with id '%v' does not have permission to impersonate users", claims.Sub)
+	}
+	if actor.ID == id {
+		return nil, fmt.Errorf("cannot impersonate yourself")
+	}
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("user with id '%v' does not exist", id)
+	}
+	if user.IsSuperUser {
+		return nil, fmt.Errorf("cannot impersonate a superuser")
+	}
+	if !user.Active {
+		return nil, fmt.Errorf("cannot impersonate an inactive user")
+	}
+
+	// O token fica no tenant ativo do superusuário, do qual o alvo precisa ser
+	// membro; assim a personificação não abre os dados de outros tenants
+	scoped, err := withTenantRoles(s.GormStore, user, claims.Tid)
+	if errors.Is(err, ErrNotTenantMember) {
+		return nil, fmt.Errorf("user with id '%v' is not a member of tenant '%v'", id, claims.Tid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ttl := s.Auth.ImpersonationTtl
+	if ttl <= 0 {
+		ttl = DefaultImpersonationTTL
+	}
+	token, err := GenerateToken(&GenToken{
+		Id:          user.ID,
+		Tid:         claims.Tid,
+		Type:        TokenAccess,
+		AppName:     s.Jwt.AppName,
+		Audience:    []string{s.Jwt.GetAudience()},
+		Permissions: ExtractCodePermissionsByUser(scoped),
+		Denies:      ExtractDeniedCodesByUser(scoped),
+		TimeZone:    s.Jwt.TimeZone,
+		JwtSecret:   s.Jwt.JwtSecret,
+		Key:         s.Jwt.SigningKey(),
+		Actor:       &Actor{Sub: actor.ID},
+		Ttl:         ttl,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Audit(&AuditLog{
+		ActorID: actor.ID,
+		UserID:  user.ID,
+		Action:  "impersonation_start",
+		IP:      client.IP,
+	})
+	s.EmitSecurityEvent(SecurityEvent{
+		Type:     SecurityEventImpersonation,
+		UserID:   user.ID,
+		ActorID:  actor.ID,
+		Username: user.Username,
+		IP:       client.IP,
+		Until:    time.Now().Add(ttl),
+	})
+	return &ImpersonationSchema{
+		AccessToken: token,
+		ExpiresIn:   int(ttl.Seconds()),
+		UserID:      user.ID,
+		ActorID:     actor.ID,
+	}, nil
+}
+
+// Audit grava um registro de auditoria. Falhas são apenas logadas para não
+// interromper a requisição.
+func (a *AppConfig) Audit(entry *AuditLog) {
+	if err := a.GormStore.Create(entry).Error; err != nil {
+		log.Printf("failed to write audit log %s actor_id=%d user_id=%d: %s", entry.Action, entry.ActorID, entry.UserID, err.Error())
+	}
+}
+
+// auditImpersonation executa a rota e registra a requisição feita com um
+// token de personificação, atribuindo-a ao superusuário em "act".
+func (a *AppConfig) auditImpersonation(ctx *fiber.Ctx, claims *JwtClaims, permissions []PermissionCode) error {
+	err := checkPermissions(ctx, claims, permissions)
+
+	status := ctx.Response().StatusCode()
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		status = fiberErr.Code
+	} else if err != nil {
+		status = fiber.StatusInternalServerError
+	}
+	log.Printf("impersonation actor_id=%d user_id=%d %s %s status=%d", claims.Act.Sub, claims.Sub, ctx.Method(), ctx.Path(), status)
+	a.Audit(&AuditLog{
+		ActorID: claims.Act.Sub,
+		UserID:  claims.Sub,
+		Action:  "impersonation_request",
+		Method:  ctx.Method(),
+		Path:    truncate(ctx.Path(), 255),
+		IP:      ctx.IP(),
+		Status:  status,
+	})
+	return err
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestImpersonateTenantScope garante que a personificação fica no tenant
+// ativo do superusuário e só alcança membros dele.
+func TestImpersonateTenantScope(t *testing.T) {
+	a := newTestApp(t)
+	acme := a.createTenant("Acme", a.superUser())
+	globex := a.createTenant("Globex")
+	bob := a.createUser("bob")
+	a.addMember(acme, bob)
+	carl := a.createUser("carl")
+	a.addMember(globex, carl)
+
+	admin, _ := a.login(testSuperUser, testSuperPass)
+	status, data, raw := a.do("POST", fmt.Sprintf("/auth/tenants/%d/switch", acme.ID), admin, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("switch: %d %s", status, raw)
+	}
+	admin = data["access_token"].(string)
+
+	cases := []struct {
+		name   string
+		target *User
+		status int
+	}{
+		{"member of the tenant", bob, fiber.StatusOK},
+		{"member of another tenant", carl, fiber.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, data, raw := a.do("POST", fmt.Sprintf("/auth/impersonate/%d", tc.target.ID), admin, nil)
+			if status != tc.status {
+				t.Fatalf("status %d, want %d: %s", status, tc.status, raw)
+			}
+			if status != fiber.StatusOK {
+				return
+			}
+			token := data["access_token"].(string)
+			if _, me, raw := a.do("GET", "/auth/me", token, nil); me["tenantId"] != float64(acme.ID) {
+				t.Fatalf("impersonation left the tenant: %s", raw)
+			}
+			status, _, raw = a.do("GET", "/users/?page=1&limit=10", token, nil)
+			if status != fiber.StatusOK || !strings.Contains(raw, `"username":"bob"`) || strings.Contains(raw, `"username":"carl"`) {
+				t.Fatalf("list: %d %s", status, raw)
+			}
+		})
+	}
+}
+
+// TestImpersonationAudit garante que o início da personificação e cada
+// requisição feita com o token ficam no log de auditoria, em nome do
+// superusuário.
+func TestImpersonationAudit(t *testing.T) {
+	a := newTestApp(t)
+	bob := a.createUser("bob")
+	admin := a.superUser()
+	access, _ := a.login(testSuperUser, testSuperPass)
+	status, data, raw := a.do("POST", fmt.Sprintf("/auth/impersonate/%d", bob.ID), access, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("impersonate: %d %s", status, raw)
+	}
+	token := data["access_token"].(string)
+	a.do("GET", "/auth/me", token, nil)
+	a.do("GET", "/tenants/?page=1&limit=10", token, nil)
+
+	want := []AuditLog{
+		{Action: "impersonation_start"},
+		{Action: "impersonation_request", Method: "GET", Path: "/auth/me", Status: fiber.StatusOK},
+		{Action: "impersonation_request", Method: "GET", Path: "/tenants/", Status: fiber.StatusUnauthorized},
+	}
+	var logs []AuditLog
+	a.r.GormStore.Order("id").Find(&logs)
+	if len(logs) != len(want) {
+		t.Fatalf("got %d audit logs, want %d: %+v", len(logs), len(want), logs)
+	}
+	for i, entry := range want {
+		got := logs[i]
+		if got.ActorID != admin.ID || got.UserID != bob.ID || got.Action != entry.Action ||
+			got.Method != entry.Method || got.Path != entry.Path || got.Status != entry.Status {
+			t.Fatalf("log %d = %+v, want %+v", i, got, entry)
+		}
+	}
+}
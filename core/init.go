@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,6 +14,8 @@ type AppJwt struct {
 	AppName          string
 	TimeZone         string
 	JwtSecret        string
+	Audience         string   // opcional, padrão AppName
+	SigningKeys      []JwtKey // opcional, substitui o HS256 com JwtSecret
 	JwtExpireAccess  time.Duration
 	JwtExpireRefresh time.Duration
 }
@@ -25,11 +28,38 @@ type AppSuper struct {
 	SuperPhone string
 }
 
+type AppAuth struct {
+	PasswordResetURL  string        // link enviado no e-mail; recebe ?token=
+	PasswordResetTtl  time.Duration // padrão DefaultPasswordResetTTL
+	PasswordChangeTtl time.Duration // validade do token de troca de senha expirada, padrão DefaultPasswordChangeTTL
+	RevealInactive    bool          // informa no login que o usuário está inativo; por padrão a resposta é genérica
+	ImpersonationTtl  time.Duration // validade do token de personificação, padrão DefaultImpersonationTTL
+	// LivePermissions faz o JWTProtected resolver permissões e status do
+	// usuário no banco (com cache) em vez de confiar no snapshot do token.
+	LivePermissions bool
+
+	LockoutThreshold   int           // falhas por usuário até o bloqueio, padrão DefaultLockoutThreshold
+	IPLockoutThreshold int           // falhas por IP até o bloqueio, padrão DefaultIPLockoutThreshold
+	LockoutDelay       time.Duration // primeiro bloqueio, dobra a cada nova falha; padrão DefaultLockoutDelay
+	LockoutMaxDelay    time.Duration // limite do backoff, padrão DefaultLockoutMaxDelay
+	LockoutWindow      time.Duration // falhas mais antigas são esquecidas, padrão DefaultLockoutWindow
+}
+
 type AppConfig struct {
-	App       *fiber.App
-	GormStore *gorm.DB
-	Jwt       AppJwt
-	Super     *AppSuper
+	App         *fiber.App
+	GormStore   *gorm.DB
+	Jwt         AppJwt
+	Super       *AppSuper
+	Auth        AppAuth
+	Mailer      Mailer              // opcional, necessário para redefinição de senha
+	Oidc        []OidcProvider      // opcional, provedores de login externos
+	Revocations *RevocationStore    // opcional, criado em NewService
+	Permissions *PermissionResolver // opcional, criado em NewService
+	Policies    PolicyEngine        // opcional, padrão NewPolicies()
+
+	Hasher          PasswordHasher      // opcional, padrão DefaultPasswordHasher
+	PasswordPolicy  *PasswordPolicy     // opcional, padrão DefaultPasswordPolicy
+	OnSecurityEvent func(SecurityEvent) // opcional, padrão log
 }
 
 type Router struct {
@@ -44,7 +74,12 @@ type Controller struct {
 
 type Service struct {
 	*AppConfig
-	TimeUCT *time.Location
+	TimeUCT   *time.Location
+	oidc      map[string]*oidcClient
+	dummyHash string // comparado quando o usuário não existe no login
+	tenantID  uint   // escopo definido por ForTenant
+
+	background *sync.WaitGroup // tarefas em segundo plano, ver WaitBackground
 }
 
 func New(config *AppConfig) *Router {
@@ -72,12 +107,29 @@ func NewService(config *AppConfig) *Service {
 	if err != nil {
 		log.Fatal(fmt.Sprintf("invalid timezone: %s", err.Error()))
 	}
+	if config.Revocations == nil {
+		config.Revocations = NewRevocationStore(config.GormStore, DefaultRevocationCacheTTL)
+	}
+	if config.Policies == nil {
+		config.Policies = NewPolicies()
+	}
+	if config.Permissions == nil {
+		config.Permissions = NewPermissionResolver(config.GormStore, DefaultPermissionCacheTTL)
+	}
 	service := &Service{
 		AppConfig: config,
 		TimeUCT:   location,
+		oidc:      newOidcClients(config.Oidc),
+
+		background: &sync.WaitGroup{},
 	}
 	if err := service.PosReady(); err != nil {
 		log.Fatal(err.Error())
 	}
+	dummyHash, err := config.HashPassword("gorote-core/dummy-password")
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	service.dummyHash = dummyHash
 	return service
 }
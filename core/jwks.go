@@ -0,0 +1,279 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JwtKey é uma chave assimétrica identificada por Kid (header "kid").
+// Chaves sem PrivateKey servem apenas para verificação, o que permite manter
+// chaves antigas ativas durante a rotação.
+type JwtKey struct {
+	Kid        string
+	PrivateKey crypto.Signer    // *rsa.PrivateKey, *ecdsa.PrivateKey ou ed25519.PrivateKey
+	PublicKey  crypto.PublicKey // opcional quando PrivateKey é informada
+}
+
+// Jwk representa uma chave pública no formato JSON Web Key (RFC 7517).
+type Jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type Jwks struct {
+	Keys []Jwk `json:"keys"`
+}
+
+// Public retorna a chave pública de verificação.
+func (k JwtKey) Public() crypto.PublicKey {
+	if k.PublicKey != nil {
+		return k.PublicKey
+	}
+	if k.PrivateKey != nil {
+		return k.PrivateKey.Public()
+	}
+	return nil
+}
+
+// Method retorna o algoritmo de assinatura correspondente ao tipo da chave.
+func (k JwtKey) Method() (jwt.SigningMethod, error) {
+	switch key := k.Public().(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported ecdsa curve for key '%s'", k.Kid)
+	default:
+		return nil, fmt.Errorf("unsupported key type for key '%s'", k.Kid)
+	}
+}
+
+// Jwk converte a parte pública da chave para o formato JWK.
+func (k JwtKey) Jwk() (Jwk, error) {
+	method, err := k.Method()
+	if err != nil {
+		return Jwk{}, err
+	}
+	jwk := Jwk{
+		Kid: k.Kid,
+		Use: "sig",
+		Alg: method.Alg(),
+	}
+	switch key := k.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(key)
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = key.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size)))
+	}
+	return jwk, nil
+}
+
+// PublicKey converte a JWK para a chave pública correspondente.
+func (k Jwk) PublicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk '%s': %s", k.Kid, err.Error())
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk '%s': %s", k.Kid, err.Error())
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported jwk curve '%s'", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk '%s': %s", k.Kid, err.Error())
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwk '%s': %s", k.Kid, err.Error())
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported jwk curve '%s'", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid jwk '%s'", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported jwk key type '%s'", k.Kty)
+	}
+}
+
+// ParsePrivateKeyPEM lê uma chave privada PEM (PKCS#8, PKCS#1 ou SEC 1).
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem private key")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type")
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported pem private key")
+}
+
+// ParsePublicKeyPEM lê uma chave pública PEM (PKIX ou PKCS#1).
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid pem public key")
+	}
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported pem public key")
+}
+
+// SigningKey retorna a chave usada para assinar novos tokens: a primeira de
+// SigningKeys com chave privada. Retorna nil quando a aplicação usa HS256.
+func (j AppJwt) SigningKey() *JwtKey {
+	for i := range j.SigningKeys {
+		if j.SigningKeys[i].PrivateKey != nil {
+			return &j.SigningKeys[i]
+		}
+	}
+	return nil
+}
+
+// Keyfunc resolve a chave de verificação pelo header "kid" quando há chaves
+// assimétricas configuradas; caso contrário usa o JwtSecret (HS256).
+func (j AppJwt) Keyfunc() jwt.Keyfunc {
+	return func(t *jwt.Token) (any, error) {
+		if len(j.SigningKeys) == 0 {
+			if t.Method != jwt.SigningMethodHS256 {
+				return nil, fmt.Errorf("unexpected signing method '%s'", t.Method.Alg())
+			}
+			return []byte(j.JwtSecret), nil
+		}
+		return lookupJwtKey(j.SigningKeys, t)
+	}
+}
+
+// lookupJwtKey retorna a chave pública indicada pelo "kid" do token, desde que
+// o algoritmo do token corresponda ao tipo da chave.
+func lookupJwtKey(keys []JwtKey, t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	for _, key := range keys {
+		if key.Kid != kid {
+			continue
+		}
+		// A chave só aceita o algoritmo correspondente ao seu tipo
+		method, err := key.Method()
+		if err != nil || method.Alg() != t.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method '%s' for key '%s'", t.Method.Alg(), kid)
+		}
+		return key.Public(), nil
+	}
+	return nil, fmt.Errorf("unknown signing key '%s'", kid)
+}
+
+// JWKS publica as chaves públicas de verificação. Segredos HS256 nunca são
+// publicados, então a lista fica vazia nesse modo.
+func (j AppJwt) JWKS() (*Jwks, error) {
+	jwks := &Jwks{Keys: []Jwk{}}
+	for _, key := range j.SigningKeys {
+		jwk, err := key.Jwk()
+		if err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
+
+func validateJwtKeys(keys []JwtKey) error {
+	seen := make(map[string]bool)
+	hasSigner := false
+	for _, key := range keys {
+		if key.Kid == "" {
+			return fmt.Errorf("jwt key without kid")
+		}
+		if seen[key.Kid] {
+			return fmt.Errorf("duplicated jwt key kid '%s'", key.Kid)
+		}
+		seen[key.Kid] = true
+		if _, err := key.Method(); err != nil {
+			return err
+		}
+		if key.PrivateKey != nil {
+			hasSigner = true
+		}
+	}
+	if !hasSigner {
+		return fmt.Errorf("no jwt key with private key to sign tokens")
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestJwksHandler garante que o endpoint publica só a parte pública das chaves
+// configuradas e que, após a rotação, a chave antiga continua publicada ao
+// lado da nova, que passa a assinar os tokens.
+func TestJwksHandler(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		keys     []JwtKey
+		wantKeys map[string]crypto.PublicKey
+		wantKid  string // kid dos novos tokens
+	}{
+		{"hs256", nil, map[string]crypto.PublicKey{}, ""},
+		{"rsa", []JwtKey{{Kid: "r1", PrivateKey: rsaKey}}, map[string]crypto.PublicKey{"r1": &rsaKey.PublicKey}, "r1"},
+		{
+			"rotated to ecdsa",
+			[]JwtKey{{Kid: "e2", PrivateKey: ecKey}, {Kid: "r1", PublicKey: &rsaKey.PublicKey}},
+			map[string]crypto.PublicKey{"e2": &ecKey.PublicKey, "r1": &rsaKey.PublicKey},
+			"e2",
+		},
+		{
+			"rotated to ed25519",
+			[]JwtKey{{Kid: "d3", PrivateKey: edKey}, {Kid: "e2", PublicKey: &ecKey.PublicKey}},
+			map[string]crypto.PublicKey{"d3": edPublic, "e2": &ecKey.PublicKey},
+			"d3",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t, func(c *AppConfig) { c.Jwt.SigningKeys = tc.keys })
+
+			status, _, raw := a.do("GET", "/.well-known/jwks.json", "", nil)
+			if status != fiber.StatusOK {
+				t.Fatalf("status %d: %s", status, raw)
+			}
+			if strings.Contains(raw, `"d"`) || strings.Contains(raw, "test-secret") {
+				t.Fatalf("private key material published: %s", raw)
+			}
+			var jwks Jwks
+			if err := json.Unmarshal([]byte(raw), &jwks); err != nil {
+				t.Fatal(err)
+			}
+			if len(jwks.Keys) != len(tc.wantKeys) {
+				t.Fatalf("served %d keys, want %d: %s", len(jwks.Keys), len(tc.wantKeys), raw)
+			}
+			for _, jwk := range jwks.Keys {
+				want, ok := tc.wantKeys[jwk.Kid]
+				if !ok {
+					t.Fatalf("unexpected kid %q", jwk.Kid)
+				}
+				got, err := jwk.PublicKey()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !want.(interface{ Equal(crypto.PublicKey) bool }).Equal(got) {
+					t.Fatalf("key %s does not match the configured public key", jwk.Kid)
+				}
+			}
+
+			access, _ := a.login(testSuperUser, testSuperPass)
+			token, _, err := jwt.NewParser().ParseUnverified(access, jwt.MapClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kid, _ := token.Header["kid"].(string); kid != tc.wantKid {
+				t.Fatalf("token kid = %q, want %q", kid, tc.wantKid)
+			}
+		})
+	}
+}
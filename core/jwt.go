@@ -0,0 +1,262 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenType identifica o uso de um token (claim "typ"), impedindo que um
+// refresh token seja aceito como access token e vice-versa.
+type TokenType string
+
+const (
+	TokenAccess  TokenType = "access"
+	TokenRefresh TokenType = "refresh"
+	TokenMfa     TokenType = "mfa"
+	// TokenPasswordChange só permite trocar uma senha expirada ou provisória
+	TokenPasswordChange TokenType = "password_change"
+	TokenApiKey         TokenType = "api_key" // claims resolvidos de uma chave de API
+)
+
+type PayloadJwt struct {
+	Token  string
+	Claims JwtClaims
+}
+type JwtClaims struct {
+	Sub         uint      `json:"sub"`
+	Sid         uint      `json:"sid,omitempty"`
+	Tid         uint      `json:"tid,omitempty"` // tenant ativo
+	Exp         int       `json:"exp"`
+	Typ         TokenType `json:"typ"`
+	Permissions []string  `json:"permissions"`
+	Denies      []string  `json:"denies,omitempty"` // negações diretas, prevalecem sobre permissions
+	IsSuperUser bool      `json:"isSuperUser"`
+	Act         *Actor    `json:"act,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Actor identifica quem age em nome do usuário do token (claim "act", RFC
+// 8693). Presente apenas em tokens de personificação.
+type Actor struct {
+	Sub uint `json:"sub"`
+}
+
+type GenToken struct {
+	Id          uint
+	Jti         string
+	Sid         uint
+	Tid         uint
+	Type        TokenType
+	AppName     string
+	Audience    []string
+	Permissions []string
+	Denies      []string
+	IsSuperUser bool
+	TimeZone    string
+	JwtSecret   string
+	Key         *JwtKey // opcional, assina com a chave assimétrica em vez do JwtSecret
+	Actor       *Actor  // opcional, emite um token de personificação
+	Ttl         time.Duration
+}
+
+func GenerateToken(gen *GenToken) (string, error) {
+	location, err := time.LoadLocation(gen.TimeZone)
+	if err != nil {
+		return "", fmt.Errorf("invalid timezone: %s", err.Error())
+	}
+	currentTime := time.Now().In(location)
+
+	accessTokenExpirationTime := currentTime.Add(gen.Ttl)
+
+	jti := gen.Jti
+	if jti == "" {
+		if jti, err = NewTokenID(); err != nil {
+			return "", err
+		}
+	}
+	tokenType := gen.Type
+	if tokenType == "" {
+		tokenType = TokenAccess
+	}
+
+	claims := jwt.MapClaims{
+		"sub":         gen.Id,
+		"jti":         jti,
+		"typ":         tokenType,
+		"iss":         gen.AppName,
+		"permissions": gen.Permissions,
+		"isSuperUser": gen.IsSuperUser,
+		"iat":         currentTime.Unix(),
+		"nbf":         currentTime.Unix(),
+		"exp":         accessTokenExpirationTime.Unix(),
+	}
+	if len(gen.Audience) > 0 {
+		claims["aud"] = gen.Audience
+	}
+	if len(gen.Denies) > 0 {
+		claims["denies"] = gen.Denies
+	}
+	if gen.Sid != 0 {
+		claims["sid"] = gen.Sid
+	}
+	if gen.Tid != 0 {
+		claims["tid"] = gen.Tid
+	}
+	if gen.Actor != nil {
+		claims["act"] = gen.Actor
+	}
+	var accessToken string
+	if gen.Key != nil {
+		method, err := gen.Key.Method()
+		if err != nil {
+			return "", err
+		}
+		accessClaims := jwt.NewWithClaims(method, claims)
+		accessClaims.Header["kid"] = gen.Key.Kid
+		accessToken, err = accessClaims.SignedString(gen.Key.PrivateKey)
+	} else {
+		accessClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		accessToken, err = accessClaims.SignedString([]byte(gen.JwtSecret))
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not sign access token string %v", err.Error())
+	}
+
+	return accessToken, nil
+}
+
+// JwtOption restringe o que GetJwtHeaderPayload aceita além da assinatura.
+type JwtOption func(*TokenVerifier, *TokenType)
+
+// WithTokenType exige que o claim "typ" seja igual a t.
+func WithTokenType(t TokenType) JwtOption {
+	return func(v *TokenVerifier, typ *TokenType) {
+		*typ = t
+	}
+}
+
+// WithKeyfunc resolve a chave de verificação com keyfunc em vez do secret.
+func WithKeyfunc(keyfunc jwt.Keyfunc) JwtOption {
+	return func(v *TokenVerifier, typ *TokenType) {
+		v.Keyfunc = keyfunc
+	}
+}
+
+// WithMethods substitui os algoritmos aceitos (padrão HS256).
+func WithMethods(methods ...string) JwtOption {
+	return func(v *TokenVerifier, typ *TokenType) {
+		v.Methods = methods
+	}
+}
+
+// WithIssuer exige que o claim "iss" seja igual a issuer.
+func WithIssuer(issuer string) JwtOption {
+	return func(v *TokenVerifier, typ *TokenType) {
+		v.Issuer = issuer
+	}
+}
+
+// WithAudience exige que o claim "aud" contenha audience.
+func WithAudience(audience string) JwtOption {
+	return func(v *TokenVerifier, typ *TokenType) {
+		v.Audience = audience
+	}
+}
+
+// GetJwtHeaderPayload valida um token HS256 assinado com secret. auth pode ser
+// o token puro ou o header Authorization completo ("Bearer <token>").
+//
+// Deprecated: use TokenVerifier ou AppJwt.Verifier.
+func GetJwtHeaderPayload(auth, secret string, opts ...JwtOption) (*PayloadJwt, error) {
+	verifier := &TokenVerifier{
+		Methods: []string{jwt.SigningMethodHS256.Alg()},
+		Keyfunc: func(t *jwt.Token) (any, error) {
+			return []byte(secret), nil
+		},
+	}
+	var tokenType TokenType
+	for _, opt := range opts {
+		opt(verifier, &tokenType)
+	}
+
+	tokenString := auth
+	if strings.Contains(auth, " ") {
+		token, err := ParseBearer(auth)
+		if err != nil {
+			return nil, err
+		}
+		tokenString = token
+	}
+
+	claims, err := verifier.Verify(tokenString, tokenType)
+	if err != nil {
+		return nil, err
+	}
+	return &PayloadJwt{
+		Token:  tokenString,
+		Claims: *claims,
+	}, nil
+}
+
+// GetExpirationTime expõe o "exp" para a validação do parser; o campo Exp
+// sobrepõe o ExpiresAt de RegisteredClaims na decodificação do JSON.
+func (c *JwtClaims) GetExpirationTime() (*jwt.NumericDate, error) {
+	if c.Exp == 0 {
+		return nil, nil
+	}
+	return jwt.NewNumericDate(time.Unix(int64(c.Exp), 0)), nil
+}
+
+// GetAudience retorna o "aud" emitido e exigido nos tokens; por padrão o AppName.
+func (j AppJwt) GetAudience() string {
+	if j.Audience != "" {
+		return j.Audience
+	}
+	return j.AppName
+}
+
+// Verifier retorna o TokenVerifier dos tokens emitidos por esta aplicação,
+// restrito aos algoritmos das chaves configuradas.
+func (j AppJwt) Verifier() *TokenVerifier {
+	methods := []string{jwt.SigningMethodHS256.Alg()}
+	if len(j.SigningKeys) > 0 {
+		methods = nil
+		for _, key := range j.SigningKeys {
+			if method, err := key.Method(); err == nil && !slices.Contains(methods, method.Alg()) {
+				methods = append(methods, method.Alg())
+			}
+		}
+	}
+	return &TokenVerifier{
+		Methods:  methods,
+		Keyfunc:  j.Keyfunc(),
+		Issuer:   j.AppName,
+		Audience: j.GetAudience(),
+	}
+}
+
+// VerifyAccessToken valida o access token do header Authorization.
+func (j AppJwt) VerifyAccessToken(auth string) (*JwtClaims, error) {
+	return j.Verifier().VerifyAuthorization(auth, TokenAccess)
+}
+
+// VerifyRefreshToken valida um refresh token emitido por esta aplicação.
+func (j AppJwt) VerifyRefreshToken(token string) (*JwtClaims, error) {
+	return j.Verifier().Verify(token, TokenRefresh)
+}
+
+// NewTokenID gera um identificador aleatório (128 bits) em hexadecimal,
+// usado como jti e como identificador de família de refresh tokens.
+func NewTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token id: %s", err.Error())
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,334 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultLockoutThreshold   = 5
+	DefaultIPLockoutThreshold = 20
+	DefaultLockoutDelay       = time.Minute
+	DefaultLockoutMaxDelay    = time.Hour
+	DefaultLockoutWindow      = 15 * time.Minute
+)
+
+// Tipos de SecurityEvent emitidos pelo módulo.
+const (
+	SecurityEventAccountLocked    = "account_locked"
+	SecurityEventAccountUnlocked  = "account_unlocked"
+	SecurityEventIPLocked         = "ip_locked"
+	SecurityEventIdentifierLocked = "identifier_locked"
+	SecurityEventImpersonation    = "impersonation_started"
+)
+
+// SecurityEvent descreve um evento relevante para auditoria de segurança.
+type SecurityEvent struct {
+	Type     string
+	UserID   uint
+	ActorID  uint // quem executou a ação, quando diferente de UserID
+	Username string
+	IP       string
+	Until    time.Time // fim do bloqueio, quando aplicável
+	Time     time.Time
+}
+
+// LockoutError é retornado pelo login enquanto a conta ou o IP estão
+// bloqueados.
+type LockoutError struct {
+	Until time.Time
+}
+
+func (e *LockoutError) Error() string {
+	return "failed to login: too many failed attempts, try again later"
+}
+
+// RetryAfter retorna quanto falta para o fim do bloqueio.
+func (e *LockoutError) RetryAfter() time.Duration {
+	return max(time.Until(e.Until), 0)
+}
+
+// LoginThrottle guarda as falhas de login por IP.
+type LoginThrottle struct {
+	gorm.Model
+	IP           string     `gorm:"uniqueIndex;size:64;not null"`
+	FailedLogins int        `gorm:"default:0"`
+	LastFailure  time.Time  `gorm:"not null"`
+	LockedUntil  *time.Time `gorm:"index"`
+}
+
+// IdentifierThrottle guarda as falhas de login por identificador informado
+// (username ou e-mail), exista a conta ou não, para que o bloqueio não revele
+// quais contas existem.
+type IdentifierThrottle struct {
+	gorm.Model
+	Identifier   string     `gorm:"uniqueIndex;size:255;not null"`
+	FailedLogins int        `gorm:"default:0"`
+	LastFailure  time.Time  `gorm:"not null"`
+	LockedUntil  *time.Time `gorm:"index"`
+}
+
+// loginIdentifier normaliza o identificador usado no login.
+func loginIdentifier(identifier string) string {
+	return strings.ToLower(strings.TrimSpace(identifier))
+}
+
+func (a *AppAuth) lockoutThreshold() int {
+	if a.LockoutThreshold > 0 {
+		return a.LockoutThreshold
+	}
+	return DefaultLockoutThreshold
+}
+
+func (a *AppAuth) ipLockoutThreshold() int {
+	if a.IPLockoutThreshold > 0 {
+		return a.IPLockoutThreshold
+	}
+	return DefaultIPLockoutThreshold
+}
+
+func (a *AppAuth) lockoutWindow() time.Duration {
+	if a.LockoutWindow > 0 {
+		return a.LockoutWindow
+	}
+	return DefaultLockoutWindow
+}
+
+// lockoutDelay aplica backoff progressivo: o bloqueio dobra a cada falha
+// acima do limite, até LockoutMaxDelay.
+func (a *AppAuth) lockoutDelay(failures int, threshold int) time.Duration {
+	delay := a.LockoutDelay
+	if delay <= 0 {
+		delay = DefaultLockoutDelay
+	}
+	maxDelay := a.LockoutMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultLockoutMaxDelay
+	}
+	for i := threshold; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// EmitSecurityEvent entrega o evento para AppConfig.OnSecurityEvent ou, se não
+// configurado, para o log.
+func (a *AppConfig) EmitSecurityEvent(event SecurityEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if a.OnSecurityEvent != nil {
+		a.OnSecurityEvent(event)
+		return
+	}
+	log.Printf("security event=%s user_id=%d actor_id=%d username=%q ip=%s until=%s",
+		event.Type, event.UserID, event.ActorID, event.Username, event.IP, event.Until.Format(time.RFC3339))
+}
+
+// checkIPLockout retorna LockoutError se o IP estiver bloqueado.
+func (s *Service) checkIPLockout(ip string) error {
+	var throttle LoginThrottle
+	err := s.GormStore.Where("ip = ?", ip).First(&throttle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query database: %w", err)
+	}
+	if throttle.LockedUntil != nil && time.Now().Before(*throttle.LockedUntil) {
+		return &LockoutError{Until: *throttle.LockedUntil}
+	}
+	return nil
+}
+
+// checkIdentifierLockout retorna LockoutError se o identificador estiver
+// bloqueado.
+func (s *Service) checkIdentifierLockout(identifier string) error {
+	var throttle IdentifierThrottle
+	err := s.GormStore.Where("identifier = ?", loginIdentifier(identifier)).First(&throttle).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query database: %w", err)
+	}
+	if throttle.LockedUntil != nil && time.Now().Before(*throttle.LockedUntil) {
+		return &LockoutError{Until: *throttle.LockedUntil}
+	}
+	return nil
+}
+
+// checkUserLockout retorna LockoutError se a conta estiver bloqueada.
+func (s *Service) checkUserLockout(user *User) error {
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		return &LockoutError{Until: *user.LockedUntil}
+	}
+	return nil
+}
+
+// registerLoginFailure contabiliza a falha para o IP, para o identificador
+// informado e, se conhecido, para o usuário, bloqueando-os ao atingir o limite
+// configurado. O contador do usuário fica na própria conta e soma as falhas
+// por username e por e-mail: alternar entre os dois não adia o bloqueio. Os
+// contadores são incrementados no próprio banco, para que tentativas paralelas
+// não sejam perdidas.
+func (s *Service) registerLoginFailure(user *User, identifier string, client ClientInfo) error {
+	now := time.Now().In(s.TimeUCT)
+
+	if client.IP != "" {
+		if err := s.GormStore.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&LoginThrottle{IP: client.IP, LastFailure: now}).Error; err != nil {
+			return fmt.Errorf("failed to register login failure: %s", err.Error())
+		}
+		threshold := s.Auth.ipLockoutThreshold()
+		until, err := s.countFailure(&LoginThrottle{}, "ip", client.IP, "last_failure", threshold, now)
+		if err != nil {
+			return err
+		}
+		if until != nil {
+			s.EmitSecurityEvent(SecurityEvent{Type: SecurityEventIPLocked, IP: client.IP, Until: *until, Time: now})
+		}
+	}
+
+	if identifier != "" {
+		identifier = loginIdentifier(identifier)
+		// Identificadores inexistentes não são zerados por um login válido:
+		// os registros fora da janela e sem bloqueio ativo são removidos aqui
+		if err := s.GormStore.Unscoped().
+			Where("last_failure < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-s.Auth.lockoutWindow()), now).
+			Delete(&IdentifierThrottle{}).Error; err != nil {
+			return fmt.Errorf("failed to purge login failures: %s", err.Error())
+		}
+		if err := s.GormStore.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&IdentifierThrottle{Identifier: identifier, LastFailure: now}).Error; err != nil {
+			return fmt.Errorf("failed to register login failure: %s", err.Error())
+		}
+		until, err := s.countFailure(&IdentifierThrottle{}, "identifier", identifier, "last_failure", s.Auth.lockoutThreshold(), now)
+		if err != nil {
+			return err
+		}
+		// Para contas existentes, o evento account_locked já registra o bloqueio
+		if until != nil && user == nil {
+			s.EmitSecurityEvent(SecurityEvent{Type: SecurityEventIdentifierLocked, Username: identifier, IP: client.IP, Until: *until, Time: now})
+		}
+	}
+
+	if user == nil {
+		return nil
+	}
+	until, err := s.countFailure(&User{}, "id", user.ID, "last_failed_login", s.Auth.lockoutThreshold(), now)
+	if err != nil {
+		return err
+	}
+	if until != nil {
+		s.EmitSecurityEvent(SecurityEvent{
+			Type:     SecurityEventAccountLocked,
+			UserID:   user.ID,
+			Username: user.Username,
+			IP:       client.IP,
+			Until:    *until,
+			Time:     now,
+		})
+	}
+	return nil
+}
+
+// countFailure incrementa atomicamente failed_logins do registro (zerando-o se
+// a última falha saiu da janela) e, ao atingir o limite, grava locked_until.
+// Retorna o fim do bloqueio quando ele foi aplicado.
+func (s *Service) countFailure(model any, column string, value any, lastColumn string, threshold int, now time.Time) (*time.Time, error) {
+	cutoff := now.Add(-s.Auth.lockoutWindow())
+	if err := s.GormStore.Model(model).Where(column+" = ?", value).Updates(map[string]any{
+		"failed_logins": gorm.Expr("CASE WHEN "+lastColumn+" IS NULL OR "+lastColumn+" < ? THEN 1 ELSE failed_logins + 1 END", cutoff),
+		lastColumn:      now,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to register login failure: %s", err.Error())
+	}
+	var failures int
+	if err := s.GormStore.Model(model).Where(column+" = ?", value).
+		Select("failed_logins").Row().Scan(&failures); err != nil {
+		return nil, fmt.Errorf("failed to register login failure: %s", err.Error())
+	}
+	if failures < threshold {
+		return nil, nil
+	}
+	until := now.Add(s.Auth.lockoutDelay(failures, threshold))
+	if err := s.GormStore.Model(model).
+		Where(column+" = ? AND failed_logins >= ?", value, threshold).
+		Update("locked_until", until).Error; err != nil {
+		return nil, fmt.Errorf("failed to register login failure: %s", err.Error())
+	}
+	return &until, nil
+}
+
+// resetIdentifierFailures zera o contador do identificador após a senha
+// correta.
+func (s *Service) resetIdentifierFailures(identifier string) error {
+	if err := s.GormStore.Unscoped().
+		Where("identifier = ?", loginIdentifier(identifier)).
+		Delete(&IdentifierThrottle{}).Error; err != nil {
+		return fmt.Errorf("failed to reset login failures: %s", err.Error())
+	}
+	return nil
+}
+
+// resetLoginFailures zera o contador do usuário após um login bem-sucedido.
+// O contador do IP expira sozinho, para que uma conta válida não o zere.
+func (s *Service) resetLoginFailures(user *User) error {
+	if user.FailedLogins == 0 && user.LockedUntil == nil {
+		return nil
+	}
+	if err := s.GormStore.Model(user).Updates(map[string]any{
+		"failed_logins":     0,
+		"last_failed_login": nil,
+		"locked_until":      nil,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to reset login failures: %s", err.Error())
+	}
+	return nil
+}
+
+// UnlockUser remove o bloqueio da conta. Exige a política PolicyAdminister
+// (por padrão, apenas superusuários).
+func (s *Service) UnlockUser(editorID uint, id uint) error {
+	editor, err := s.GetUserByID(editorID)
+	if err != nil {
+		return fmt.Errorf("user editor with id '%v' does not exist", editorID)
+	}
+	user, err := s.GetUserByID(id)
+	if err != nil {
+		return fmt.Errorf("user with id '%v' does not exist", id)
+	}
+	subject, err := s.subjectClaims(editor)
+	if err != nil {
+		return err
+	}
+	if err := s.Can(subject, PolicyAdminister, userResource(user)); err != nil {
+		return err
+	}
+	if err := s.GormStore.Model(user).Updates(map[string]any{
+		"failed_logins":     0,
+		"last_failed_login": nil,
+		"locked_until":      nil,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to unlock user: %s", err.Error())
+	}
+	if err := s.GormStore.Unscoped().
+		Where("identifier IN ?", []string{loginIdentifier(user.Username), loginIdentifier(user.Email)}).
+		Delete(&IdentifierThrottle{}).Error; err != nil {
+		return fmt.Errorf("failed to unlock user: %s", err.Error())
+	}
+	s.EmitSecurityEvent(SecurityEvent{
+		Type:     SecurityEventAccountUnlocked,
+		UserID:   user.ID,
+		ActorID:  editor.ID,
+		Username: user.Username,
+	})
+	return nil
+}
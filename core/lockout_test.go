@@ -0,0 +1,158 @@
+package core
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestLoginLockoutHidesAccountExistence garante que contas existentes e
+// inexistentes são bloqueadas da mesma forma, com a mesma resposta.
+func TestLoginLockoutHidesAccountExistence(t *testing.T) {
+	cases := []struct {
+		name     string
+		username string
+	}{
+		{"existing username", "bob"},
+		{"existing email", "bob@example.com"},
+		{"unknown username", "ghost"},
+	}
+	want := []int{fiber.StatusBadRequest, fiber.StatusBadRequest, fiber.StatusBadRequest, fiber.StatusTooManyRequests}
+	var bodies []string
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t, func(c *AppConfig) { c.Auth.LockoutThreshold = 3 })
+			a.createUser("bob")
+			var body string
+			for i, status := range want {
+				got, data, raw := a.do("POST", "/auth/login", "", map[string]any{"username": tc.username, "password": "Wrong@123"})
+				if got != status {
+					t.Fatalf("attempt %d: %d %s", i+1, got, raw)
+				}
+				body, _ = data["message"].(string)
+				if body == "" {
+					body = raw
+				}
+			}
+			bodies = append(bodies, body)
+		})
+	}
+	for _, body := range bodies[1:] {
+		if body != bodies[0] {
+			t.Fatalf("lockout responses differ: %q vs %q", body, bodies[0])
+		}
+	}
+}
+
+// TestLoginLockoutResetOnSuccess garante que a senha correta zera o contador
+// do identificador e que o desbloqueio administrativo também o libera.
+func TestLoginLockoutResetOnSuccess(t *testing.T) {
+	a := newTestApp(t, func(c *AppConfig) { c.Auth.LockoutThreshold = 3 })
+	bob := a.createUser("bob")
+	wrong := map[string]any{"username": "bob", "password": "Wrong@123"}
+
+	for range 2 {
+		a.do("POST", "/auth/login", "", wrong)
+	}
+	a.login("bob", testPassword)
+	for range 2 {
+		if status, _, raw := a.do("POST", "/auth/login", "", wrong); status != fiber.StatusBadRequest {
+			t.Fatalf("counter not reset: %d %s", status, raw)
+		}
+	}
+
+	a.do("POST", "/auth/login", "", wrong)
+	if status, _, raw := a.do("POST", "/auth/login", "", wrong); status != fiber.StatusTooManyRequests {
+		t.Fatalf("want lockout: %d %s", status, raw)
+	}
+	if err := a.r.Controller.Service.UnlockUser(a.superUser().ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	a.login("bob", testPassword)
+}
+
+// TestLoginLockoutAcrossIdentifiers garante que o contador da conta soma as
+// falhas por username e por e-mail.
+func TestLoginLockoutAcrossIdentifiers(t *testing.T) {
+	a := newTestApp(t, func(c *AppConfig) { c.Auth.LockoutThreshold = 3 })
+	a.createUser("bob")
+	for _, username := range []string{"bob", "bob@example.com", "bob"} {
+		if status, _, raw := a.do("POST", "/auth/login", "", map[string]any{"username": username, "password": "Wrong@123"}); status != fiber.StatusBadRequest {
+			t.Fatalf("%s: %d %s", username, status, raw)
+		}
+	}
+	if status, _, raw := a.do("POST", "/auth/login", "", map[string]any{"username": "bob@example.com", "password": testPassword}); status != fiber.StatusTooManyRequests {
+		t.Fatalf("want lockout: %d %s", status, raw)
+	}
+}
+
+// TestLoginFailuresConcurrent garante que falhas paralelas não se perdem:
+// cada uma incrementa o contador no banco, mesmo com o usuário já carregado.
+func TestLoginFailuresConcurrent(t *testing.T) {
+	const attempts = 10
+	a := newTestApp(t, func(c *AppConfig) { c.Auth.LockoutThreshold = attempts })
+	bob := a.createUser("bob")
+	service := a.r.Controller.Service
+
+	var wg sync.WaitGroup
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := service.registerLoginFailure(bob, "bob", ClientInfo{IP: "10.0.0.1"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var user User
+	a.r.GormStore.First(&user, bob.ID)
+	if user.FailedLogins != attempts || user.LockedUntil == nil {
+		t.Fatalf("failed_logins = %d, locked_until = %v", user.FailedLogins, user.LockedUntil)
+	}
+	var throttle IdentifierThrottle
+	a.r.GormStore.Where("identifier = ?", "bob").First(&throttle)
+	if throttle.FailedLogins != attempts {
+		t.Fatalf("identifier failed_logins = %d", throttle.FailedLogins)
+	}
+}
+
+// TestIdentifierThrottlePurge garante que uma nova falha remove os
+// identificadores fora da janela, preservando os ainda bloqueados.
+func TestIdentifierThrottlePurge(t *testing.T) {
+	a := newTestApp(t)
+	now := time.Now()
+	lockedUntil := now.Add(time.Hour)
+	a.r.GormStore.Create(&IdentifierThrottle{Identifier: "stale", FailedLogins: 2, LastFailure: now.Add(-2 * DefaultLockoutWindow)})
+	a.r.GormStore.Create(&IdentifierThrottle{Identifier: "locked", FailedLogins: 9, LastFailure: now.Add(-2 * DefaultLockoutWindow), LockedUntil: &lockedUntil})
+	a.r.GormStore.Create(&IdentifierThrottle{Identifier: "recent", FailedLogins: 1, LastFailure: now})
+
+	a.do("POST", "/auth/login", "", map[string]any{"username": "ghost", "password": "Wrong@123"})
+
+	var identifiers []string
+	a.r.GormStore.Unscoped().Model(&IdentifierThrottle{}).Order("identifier").Pluck("identifier", &identifiers)
+	if got := strings.Join(identifiers, ","); got != "ghost,locked,recent" {
+		t.Fatalf("identifiers = %s", got)
+	}
+}
+
+// TestUnlockUserAuditsActor garante que o evento de desbloqueio registra quem
+// desbloqueou a conta.
+func TestUnlockUserAuditsActor(t *testing.T) {
+	var events []SecurityEvent
+	a := newTestApp(t, func(c *AppConfig) {
+		c.OnSecurityEvent = func(event SecurityEvent) { events = append(events, event) }
+	})
+	bob := a.createUser("bob")
+	admin := a.superUser()
+	if err := a.r.Controller.Service.UnlockUser(admin.ID, bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != SecurityEventAccountUnlocked || events[0].UserID != bob.ID || events[0].ActorID != admin.ID {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mail é uma mensagem enviada pela aplicação (ex.: redefinição de senha).
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer entrega e-mails da aplicação. Configure em AppConfig.Mailer.
+type Mailer interface {
+	Send(ctx context.Context, mail Mail) error
+}
+
+// LogMailer escreve os e-mails no log. Use apenas em desenvolvimento: o corpo
+// das mensagens pode conter tokens.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, mail Mail) error {
+	log.Printf("mail to=%s subject=%q\n%s", mail.To, mail.Subject, mail.Body)
+	return nil
+}
+
+// FileMailer grava cada e-mail como um arquivo .eml em Dir, útil em testes.
+type FileMailer struct {
+	Dir string
+}
+
+func (m FileMailer) Send(ctx context.Context, mail Mail) error {
+	if err := os.MkdirAll(m.Dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create mail dir: %s", err.Error())
+	}
+	id, err := NewTokenID()
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), id[:8])
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&content, "Subject: %s\r\n", mail.Subject)
+	fmt.Fprintf(&content, "Date: %s\r\n\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	content.WriteString(mail.Body)
+
+	if err := os.WriteFile(filepath.Join(m.Dir, name), []byte(content.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to write mail: %s", err.Error())
+	}
+	return nil
+}
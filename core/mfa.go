@@ -0,0 +1,251 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// MfaChallengeTTL é a validade do token emitido entre a senha e o código TOTP.
+	MfaChallengeTTL = 5 * time.Minute
+	// MfaRecoveryCodes é a quantidade de códigos de recuperação gerados.
+	MfaRecoveryCodes = 10
+)
+
+// ErrInvalidMfaCode indica um código TOTP ou de recuperação inválido.
+var ErrInvalidMfaCode = errors.New("invalid mfa code")
+
+// MfaChallenge emite o token de desafio retornado pelo login quando o
+// usuário tem MFA habilitado.
+func (s *Service) MfaChallenge(user *User) (*MfaChallenge, error) {
+	token, err := GenerateToken(&GenToken{
+		Id:        user.ID,
+		Type:      TokenMfa,
+		AppName:   s.Jwt.AppName,
+		Audience:  []string{s.Jwt.GetAudience()},
+		TimeZone:  s.Jwt.TimeZone,
+		JwtSecret: s.Jwt.JwtSecret,
+		Key:       s.Jwt.SigningKey(),
+		Ttl:       MfaChallengeTTL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MfaChallenge{
+		MfaRequired: true,
+		MfaToken:    token,
+	}, nil
+}
+
+// LoginMfa conclui o login validando o token de desafio e um código TOTP ou
+// de recuperação. O token de desafio só pode ser usado uma vez. Códigos
+// inválidos contam como falhas de login do usuário e do IP: ao bloquear a
+// conta, o token de desafio é revogado.
+func (s *Service) LoginMfa(req *LoginMfa, client ClientInfo) (*User, error) {
+	if err := s.checkIPLockout(client.IP); err != nil {
+		return nil, err
+	}
+	claims, err := s.Jwt.Verifier().Verify(req.MfaToken, TokenMfa)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mfa token: %s", err.Error())
+	}
+	revoked, err := s.Revocations.IsRevoked(claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, fmt.Errorf("invalid mfa token: %s", ErrTokenRevoked.Error())
+	}
+
+	user, err := s.GetUserByID(claims.Sub)
+	if err != nil || !user.Active || !user.MfaEnabled {
+		return nil, fmt.Errorf("failed to login: invalid mfa token")
+	}
+	if err := s.checkUserLockout(user); err != nil {
+		return nil, err
+	}
+	expiresAt := time.Unix(int64(claims.Exp), 0)
+	if err := s.verifyMfaCode(user, req.Code); err != nil {
+		if !errors.Is(err, ErrInvalidMfaCode) {
+			return nil, err
+		}
+		if err := s.registerLoginFailure(user, "", client); err != nil {
+			return nil, err
+		}
+		// Atingido o limite, o desafio é descartado junto com o bloqueio
+		current, err := s.GetUserByID(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if lockout := s.checkUserLockout(current); lockout != nil {
+			if err := s.Revocations.RevokeToken(claims.ID, claims.Sub, expiresAt); err != nil {
+				return nil, err
+			}
+			return nil, lockout
+		}
+		return nil, ErrInvalidMfaCode
+	}
+
+	if err := s.Revocations.RevokeToken(claims.ID, claims.Sub, expiresAt); err != nil {
+		return nil, err
+	}
+	if err := s.resetLoginFailures(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// EnrollMfa gera um novo segredo TOTP pendente para o usuário. O MFA só é
+// habilitado após a confirmação do primeiro código em ConfirmMfa.
+func (s *Service) EnrollMfa(userID uint) (*MfaEnrollment, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MfaEnabled {
+		return nil, fmt.Errorf("mfa is already enabled")
+	}
+	secret, err := GenerateTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.GormStore.Model(user).Updates(map[string]any{
+		"mfa_secret":    secret,
+		"mfa_last_step": 0,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to enroll mfa: %s", err.Error())
+	}
+	return &MfaEnrollment{
+		Secret: secret,
+		URI:    TotpProvisioningURI(s.Jwt.AppName, user.Username, secret),
+	}, nil
+}
+
+// ConfirmMfa valida o primeiro código do segredo pendente, habilita o MFA e
+// retorna os códigos de recuperação (exibidos apenas uma vez).
+func (s *Service) ConfirmMfa(userID uint, req *MfaCode) (*MfaRecovery, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MfaEnabled {
+		return nil, fmt.Errorf("mfa is already enabled")
+	}
+	if user.MfaSecret == "" {
+		return nil, fmt.Errorf("mfa enrollment not started")
+	}
+	step, ok := ValidateTotp(user.MfaSecret, req.Code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMfaCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	tx := s.GormStore.Begin()
+	if err := tx.Where("user_id = ?", user.ID).Delete(&MfaRecoveryCode{}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to reset recovery codes: %s", err.Error())
+	}
+	for _, hash := range hashes {
+		if err := tx.Create(&MfaRecoveryCode{UserID: user.ID, CodeHash: hash}).Error; err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to store recovery codes: %s", err.Error())
+		}
+	}
+	if err := tx.Model(user).Updates(map[string]any{
+		"mfa_enabled":   true,
+		"mfa_last_step": step,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to enable mfa: %s", err.Error())
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to enable mfa: %s", err.Error())
+	}
+
+	return &MfaRecovery{RecoveryCodes: codes}, nil
+}
+
+// DisableMfa desabilita o MFA mediante um código TOTP ou de recuperação válido.
+func (s *Service) DisableMfa(userID uint, req *MfaCode) error {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.MfaEnabled {
+		return fmt.Errorf("mfa is not enabled")
+	}
+	if err := s.verifyMfaCode(user, req.Code); err != nil {
+		return err
+	}
+	if err := s.GormStore.Where("user_id = ?", user.ID).Delete(&MfaRecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("failed to remove recovery codes: %s", err.Error())
+	}
+	if err := s.GormStore.Model(user).Updates(map[string]any{
+		"mfa_enabled":   false,
+		"mfa_secret":    "",
+		"mfa_last_step": 0,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to disable mfa: %s", err.Error())
+	}
+	return nil
+}
+
+// verifyMfaCode aceita um código TOTP ainda não usado ou um código de
+// recuperação, que é consumido.
+func (s *Service) verifyMfaCode(user *User, code string) error {
+	if step, ok := ValidateTotp(user.MfaSecret, code, time.Now()); ok {
+		// Impede o reuso do mesmo código (ou de um anterior) dentro da janela
+		result := s.GormStore.Model(&User{}).
+			Where("id = ? AND mfa_last_step < ?", user.ID, step).
+			Update("mfa_last_step", step)
+		if result.Error != nil {
+			return fmt.Errorf("failed to verify mfa code: %s", result.Error.Error())
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidMfaCode
+		}
+		return nil
+	}
+
+	result := s.GormStore.Model(&MfaRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(code)).
+		Update("used_at", time.Now().In(s.TimeUCT))
+	if result.Error != nil {
+		return fmt.Errorf("failed to verify mfa code: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMfaCode
+	}
+	return nil
+}
+
+func generateRecoveryCodes() ([]string, []string, error) {
+	var codes, hashes []string
+	for range MfaRecoveryCodes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery codes: %s", err.Error())
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(b))
+		code = code[:4] + "-" + code[4:]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode normaliza e aplica SHA-256 ao código de recuperação; os
+// códigos são aleatórios, então um hash rápido é suficiente.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const testMfaSecret = "JBSWY3DPEHPK3PXP"
+
+// mfaUser cria um usuário com MFA habilitado e retorna o token de desafio
+// emitido para ele.
+func (a *testApp) mfaUser(username string) (*User, string) {
+	a.t.Helper()
+	user := a.createUser(username)
+	if err := a.r.GormStore.Model(user).Updates(map[string]any{"mfa_enabled": true, "mfa_secret": testMfaSecret}).Error; err != nil {
+		a.t.Fatal(err)
+	}
+	challenge, err := a.r.Controller.Service.MfaChallenge(user)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return user, challenge.MfaToken
+}
+
+func validMfaCode(t *testing.T) string {
+	t.Helper()
+	code, err := TotpCode(testMfaSecret, TotpStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// TestLoginMfaFailures garante que códigos inválidos contam contra a conta e
+// que, ao bloqueá-la, o token de desafio é descartado.
+func TestLoginMfaFailures(t *testing.T) {
+	a := newTestApp(t, func(c *AppConfig) { c.Auth.LockoutThreshold = 3 })
+	user, token := a.mfaUser("bob")
+
+	steps := []struct {
+		name     string
+		code     string
+		status   int
+		wantBody string
+	}{
+		{"first miss", "000000", fiber.StatusUnauthorized, "invalid mfa code"},
+		{"second miss", "000000", fiber.StatusUnauthorized, "invalid mfa code"},
+		{"third miss locks", "000000", fiber.StatusTooManyRequests, "too many failed attempts"},
+		{"valid code while locked", validMfaCode(t), fiber.StatusUnauthorized, "revoked"},
+	}
+	for _, step := range steps {
+		status, _, raw := a.do("POST", "/auth/login/mfa", "", map[string]any{"mfa_token": token, "code": step.code})
+		if status != step.status || !strings.Contains(raw, step.wantBody) {
+			t.Fatalf("%s: %d %s", step.name, status, raw)
+		}
+	}
+
+	// Mesmo após o desbloqueio, o desafio antigo continua inválido
+	a.r.GormStore.Model(user).Updates(map[string]any{"failed_logins": 0, "locked_until": nil})
+	if status, _, raw := a.do("POST", "/auth/login/mfa", "", map[string]any{"mfa_token": token, "code": validMfaCode(t)}); status != fiber.StatusUnauthorized {
+		t.Fatalf("revoked challenge accepted: %d %s", status, raw)
+	}
+}
+
+// TestLoginMfaFailuresSurvivePassword garante que um novo login com a senha
+// correta não zera as falhas de MFA; só o segundo fator zera o contador.
+func TestLoginMfaFailuresSurvivePassword(t *testing.T) {
+	a := newTestApp(t)
+	user, token := a.mfaUser("bob")
+
+	if status, _, raw := a.do("POST", "/auth/login/mfa", "", map[string]any{"mfa_token": token, "code": "000000"}); status != fiber.StatusUnauthorized {
+		t.Fatalf("miss: %d %s", status, raw)
+	}
+	status, data, raw := a.do("POST", "/auth/login", "", map[string]any{"username": "bob", "password": testPassword})
+	if status != fiber.StatusOK || data["mfa_required"] != true {
+		t.Fatalf("password: %d %s", status, raw)
+	}
+	failures := func() int {
+		var current User
+		a.r.GormStore.First(&current, user.ID)
+		return current.FailedLogins
+	}
+	if got := failures(); got != 1 {
+		t.Fatalf("failed_logins = %d after password login, want 1", got)
+	}
+
+	token = data["mfa_token"].(string)
+	if status, _, raw := a.do("POST", "/auth/login/mfa", "", map[string]any{"mfa_token": token, "code": validMfaCode(t)}); status != fiber.StatusOK {
+		t.Fatalf("second factor: %d %s", status, raw)
+	}
+	if got := failures(); got != 0 {
+		t.Fatalf("failed_logins = %d after second factor, want 0", got)
+	}
+}
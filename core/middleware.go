@@ -1,10 +1,10 @@
 package core
 
 import (
+	"errors"
 	"fmt"
-	"log"
 	"reflect"
-	"slices"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/contrib/websocket"
@@ -22,48 +22,147 @@ func IsWsMiddleware() fiber.Handler {
 	}
 }
 
+// JWTProtected valida o access token do header Authorization (assinatura,
+// tipo, issuer, audience e revogação) ou uma chave de API ("ApiKey <chave>")
+// e, se informadas, exige ao menos uma das permissões. Com
+// AppAuth.LivePermissions, as permissões vêm do banco e não do token.
+// Os claims validados ficam disponíveis em ctx.Locals("claims").
+func (a *AppConfig) JWTProtected(permissions ...PermissionCode) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		// Chaves de API: "Authorization: ApiKey <chave>"
+		if scheme, key, found := strings.Cut(ctx.Get("Authorization"), " "); found && strings.EqualFold(scheme, "ApiKey") {
+			claims, err := a.VerifyApiKey(key)
+			if err != nil {
+				return unauthorized(ctx, err)
+			}
+			ctx.Locals("claims", claims)
+			return checkPermissions(ctx, claims, permissions)
+		}
+
+		claims, err := a.Jwt.VerifyAccessToken(ctx.Get("Authorization"))
+		if err != nil {
+			return unauthorized(ctx, err)
+		}
+		if a.Revocations != nil {
+			revoked, err := a.Revocations.IsRevoked(claims)
+			if err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+			}
+			if revoked {
+				return unauthorized(ctx, ErrTokenRevoked)
+			}
+		}
+		if a.Auth.LivePermissions && a.Permissions != nil {
+			live, err := a.Permissions.Apply(claims)
+			if errors.Is(err, ErrUserInactive) {
+				return unauthorized(ctx, err)
+			}
+			if err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+			}
+			claims = live
+		}
+		ctx.Locals("claims", claims)
+
+		if claims.Act != nil {
+			return a.auditImpersonation(ctx, claims, permissions)
+		}
+		return checkPermissions(ctx, claims, permissions)
+	}
+}
+
+// Deprecated: use AppConfig.JWTProtected
 func JWTProtected(jwtSecret string, permissions ...PermissionCode) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		token, err := GetJwtHeaderPayload(ctx.Get("Authorization"), jwtSecret)
+		token, err := GetJwtHeaderPayload(ctx.Get("Authorization"), jwtSecret, WithTokenType(TokenAccess))
 		if err != nil {
-			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+			return unauthorized(ctx, err)
 		}
+		ctx.Locals("claims", &token.Claims)
+
+		return checkPermissions(ctx, &token.Claims, permissions)
+	}
+}
+
+// unauthorized responde 401 com o motivo da falha também no WWW-Authenticate.
+func unauthorized(ctx *fiber.Ctx, err error) error {
+	ctx.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer error="invalid_token", error_description="%s"`, err.Error()))
+	return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+}
 
-		// Check permissions
-		if token.Claims.IsSuperUser {
-			return ctx.Next()
+// checkPermissions exige ao menos uma das permissões (AnyOf). Mantém o 401
+// histórico; para requisitos compostos, use Require.
+func checkPermissions(ctx *fiber.Ctx, claims *JwtClaims, permissions []PermissionCode) error {
+	if len(permissions) == 0 {
+		return ctx.Next()
+	}
+	requirements := make([]Requirement, 0, len(permissions))
+	for _, permission := range permissions {
+		requirements = append(requirements, permission)
+	}
+	requirement := AnyOf(requirements...)
+	if err := Authorize(claims, requirement); err != nil {
+		logDenied(ctx, claims, requirement)
+		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+	}
+	return ctx.Next()
+}
+
+// DenyApiKey bloqueia chaves de API em rotas de gerenciamento da conta
+// (senha, MFA, chaves); deve vir depois de JWTProtected.
+func DenyApiKey() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, err := GetClaims(ctx)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
 		}
-		if len(permissions) == 0 {
-			return ctx.Next()
+		if claims.Typ == TokenApiKey {
+			return fiber.NewError(fiber.StatusForbidden, "api keys cannot be used on this route")
 		}
+		return ctx.Next()
+	}
+}
 
-		// Check if any required permission exists in user's permissions
-		for _, requiredPermission := range permissions {
-			if slices.Contains(token.Claims.Permissions, string(requiredPermission)) {
-				log.Println("Permission validated, proceeding to next handler")
-				return ctx.Next()
-			}
+// DenyImpersonation bloqueia tokens de personificação em rotas de
+// gerenciamento da conta; deve vir depois de JWTProtected.
+func DenyImpersonation() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, err := GetClaims(ctx)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
 		}
+		if claims.Act != nil {
+			return fiber.NewError(fiber.StatusForbidden, "impersonation tokens cannot be used on this route")
+		}
+		return ctx.Next()
+	}
+}
 
-		// If no errors, log success and continue to the next handler
-		log.Println("JWT validated and session matched, proceeding to next handler")
-		return fiber.NewError(fiber.StatusUnauthorized, "unauthorized")
+// GetClaims retorna os claims armazenados por JWTProtected.
+func GetClaims(ctx *fiber.Ctx) (*JwtClaims, error) {
+	claims, ok := ctx.Locals("claims").(*JwtClaims)
+	if !ok || claims == nil {
+		return nil, fmt.Errorf("missing jwt claims")
 	}
+	return claims, nil
 }
 
+// ValidationMiddleware faz o parse e a validação da requisição no tipo de
+// requestStruct, que serve apenas de modelo: cada requisição recebe um valor
+// novo, para que campos ausentes não herdem dados de requisições anteriores.
 func ValidationMiddleware(requestStruct any) fiber.Handler {
 	return func(ctx *fiber.Ctx) error {
-		v := reflect.ValueOf(requestStruct)
-		if v.Kind() == reflect.Ptr {
-			v = v.Elem() // Dereferencia o ponteiro para obter o valor subjacente
+		t := reflect.TypeOf(requestStruct)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem() // Dereferencia o ponteiro para obter o tipo subjacente
 		}
 
-		// Verifica se o valor subjacente é uma struct
-		if v.Kind() != reflect.Struct {
+		// Verifica se o tipo subjacente é uma struct
+		if t.Kind() != reflect.Struct {
 			return fiber.NewError(fiber.StatusInternalServerError, "validation target must be a struct")
 		}
 
-		t := v.Type()
+		data := reflect.New(t).Interface()
 		var foundTag bool
 		var parseErr error
 
@@ -74,7 +173,7 @@ func ValidationMiddleware(requestStruct any) fiber.Handler {
 			// Verifica tags de query
 			if _, ok := field.Tag.Lookup("query"); ok {
 				foundTag = true
-				if parseErr = ctx.QueryParser(requestStruct); parseErr != nil {
+				if parseErr = ctx.QueryParser(data); parseErr != nil {
 					return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid query parameters: %s", parseErr.Error()))
 				}
 				break
@@ -83,7 +182,7 @@ func ValidationMiddleware(requestStruct any) fiber.Handler {
 			// Verifica tags de json
 			if _, ok := field.Tag.Lookup("json"); ok {
 				foundTag = true
-				if parseErr = ctx.BodyParser(requestStruct); parseErr != nil {
+				if parseErr = ctx.BodyParser(data); parseErr != nil {
 					return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid body: %s", parseErr.Error()))
 				}
 				break
@@ -92,7 +191,7 @@ func ValidationMiddleware(requestStruct any) fiber.Handler {
 			// Verifica tags de params (URL parameters)
 			if _, ok := field.Tag.Lookup("params"); ok {
 				foundTag = true
-				if parseErr = ctx.ParamsParser(requestStruct); parseErr != nil {
+				if parseErr = ctx.ParamsParser(data); parseErr != nil {
 					return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid URL parameters: %s", parseErr.Error()))
 				}
 				break
@@ -104,12 +203,12 @@ func ValidationMiddleware(requestStruct any) fiber.Handler {
 		}
 
 		// Valide os dados usando o validator
-		if err := validateStruct(requestStruct); err != nil {
+		if err := validateStruct(data); err != nil {
 			return ctx.Status(fiber.StatusBadRequest).JSON(err)
 		}
 
 		// Armazene os dados validados no contexto
-		ctx.Locals("validatedData", requestStruct)
+		ctx.Locals("validatedData", data)
 
 		// Prossiga para o próximo middleware ou handler
 		return ctx.Next()
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestValidationMiddlewareFreshValue garante que campos ausentes em uma
+// requisição não herdam os valores da requisição anterior.
+func TestValidationMiddlewareFreshValue(t *testing.T) {
+	app := fiber.New()
+	app.Post("/", ValidationMiddleware(&ChangeExpiredPassword{}), func(ctx *fiber.Ctx) error {
+		req := ctx.Locals("validatedData").(*ChangeExpiredPassword)
+		return ctx.SendString(req.PasswordChangeToken + "|" + req.NewPassword)
+	})
+	send := func(body string) (int, string) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		res, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(raw)
+	}
+
+	if status, raw := send(`{"password_change_token":"VICTIM","new_password":"weak"}`); status != fiber.StatusOK || raw != "VICTIM|weak" {
+		t.Fatalf("first request: %d %s", status, raw)
+	}
+	if status, raw := send(`{"new_password":"Attacker!1"}`); status != fiber.StatusBadRequest {
+		t.Fatalf("second request reused the previous token: %d %s", status, raw)
+	}
+}
+
+func TestValidationMiddlewareQueryFreshValue(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", ValidationMiddleware(&OidcCallback{}), func(ctx *fiber.Ctx) error {
+		req := ctx.Locals("validatedData").(*OidcCallback)
+		return ctx.SendString(fmt.Sprintf("%s|%s|%s", req.Code, req.State, req.Error))
+	})
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"?state=s1&error=access_denied", "|s1|access_denied"},
+		{"?state=s2&code=good", "good|s2|"},
+	}
+	for _, tt := range tests {
+		res, err := app.Test(httptest.NewRequest("GET", "/"+tt.query, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := io.ReadAll(res.Body)
+		if string(raw) != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.query, raw, tt.want)
+		}
+	}
+}
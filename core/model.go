@@ -1,6 +1,8 @@
 package core
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -14,9 +16,11 @@ type Permission struct {
 
 type Role struct {
 	gorm.Model
-	Name        string       `gorm:"uniqueIndex;size:100;not null" validate:"required,min=3,max=100"`
+	TenantID    uint         `gorm:"uniqueIndex:idx_roles_tenant_name;default:0"` // zero para roles globais
+	Name        string       `gorm:"uniqueIndex:idx_roles_tenant_name;size:100;not null" validate:"required,min=3,max=100"`
 	Description string       `gorm:"size:255"`
 	Permissions []Permission `gorm:"many2many:roles_permissions"`
+	Parents     []Role       `gorm:"many2many:roles_parents;joinForeignKey:RoleID;joinReferences:ParentID"` // roles herdadas
 	Active      bool         `gorm:"default:true"`
 }
 
@@ -33,4 +37,185 @@ type User struct {
 	Roles       []Role `gorm:"many2many:users_roles"`
 	Phone1      string `gorm:"type:varchar(20);not null" validate:"required,e164"`
 	Phone2      string `gorm:"type:varchar(20);nullable" validate:"omitempty,e164"`
+	MfaEnabled  bool   `gorm:"default:false"`
+	MfaSecret   string `gorm:"size:64"`
+	MfaLastStep int64  `gorm:"default:0"` // última janela TOTP aceita, impede reuso do código
+
+	// Bloqueio por tentativas de login malsucedidas
+	FailedLogins    int `gorm:"default:0"`
+	LastFailedLogin *time.Time
+	LockedUntil     *time.Time
+
+	// Histórico e expiração da senha
+	PasswordChangedAt  *time.Time
+	MustChangePassword bool `gorm:"default:false"`
+
+	// Permissões concedidas ou negadas diretamente, fora das roles
+	PermissionOverrides []UserPermission
+}
+
+// UserPermission concede (allow) ou nega (deny) uma permissão diretamente ao
+// usuário. A negação prevalece sobre qualquer concessão, direta ou por role.
+// Com TenantID, vale apenas no tenant; sem, vale em todos, como as roles
+// globais.
+type UserPermission struct {
+	gorm.Model
+	UserID       uint             `gorm:"uniqueIndex:idx_user_permissions_tenant_user_permission;not null"`
+	User         User             `gorm:"constraint:OnDelete:CASCADE"`
+	TenantID     uint             `gorm:"uniqueIndex:idx_user_permissions_tenant_user_permission;default:0"`
+	PermissionID uint             `gorm:"uniqueIndex:idx_user_permissions_tenant_user_permission;not null"`
+	Permission   Permission       `gorm:"constraint:OnDelete:CASCADE"`
+	Effect       PermissionEffect `gorm:"size:10;not null"`
+}
+
+// Tenant é uma organização cliente. Usuários participam por meio de
+// Membership, com roles próprias do tenant.
+type Tenant struct {
+	gorm.Model
+	Name   string `gorm:"uniqueIndex;size:100;not null"`
+	Active bool   `gorm:"default:true"`
+}
+
+// Membership vincula um usuário a um tenant, com as roles que ele tem nele.
+type Membership struct {
+	gorm.Model
+	UserID   uint   `gorm:"uniqueIndex:idx_memberships_user_tenant;not null"`
+	User     User   `gorm:"constraint:OnDelete:CASCADE"`
+	TenantID uint   `gorm:"uniqueIndex:idx_memberships_user_tenant;not null"`
+	Tenant   Tenant `gorm:"constraint:OnDelete:CASCADE"`
+	Roles    []Role `gorm:"many2many:memberships_roles"`
+	Active   bool   `gorm:"default:true"`
+}
+
+// RefreshToken registra cada refresh token emitido. Cada token é de uso único e
+// pertence a uma família; o reuso de um token revoga a família inteira.
+type RefreshToken struct {
+	gorm.Model
+	Jti       string    `gorm:"uniqueIndex;size:64;not null"`
+	FamilyID  string    `gorm:"index;size:64;not null"`
+	UserID    uint      `gorm:"index;not null"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// RevokedToken é um token revogado antes da expiração (logout), indexado por jti.
+type RevokedToken struct {
+	gorm.Model
+	Jti       string    `gorm:"uniqueIndex;size:64;not null"`
+	UserID    uint      `gorm:"index;not null"`
+	ExpiresAt time.Time `gorm:"index;not null"`
+}
+
+// UserRevocation invalida todos os tokens do usuário emitidos até RevokedBefore.
+type UserRevocation struct {
+	gorm.Model
+	UserID        uint      `gorm:"uniqueIndex;not null"`
+	RevokedBefore time.Time `gorm:"not null"`
+}
+
+// Session representa um login do usuário. A sessão é dona de uma família de
+// refresh tokens e é revogada junto com ela.
+type Session struct {
+	gorm.Model
+	UserID     uint      `gorm:"index;not null"`
+	User       User      `gorm:"constraint:OnDelete:CASCADE"`
+	FamilyID   string    `gorm:"uniqueIndex;size:64;not null"`
+	UserAgent  string    `gorm:"size:255"`
+	IP         string    `gorm:"size:45"`
+	LastSeenAt time.Time `gorm:"not null"`
+	TenantID   uint      `gorm:"default:0"` // tenant ativo da sessão
+	RevokedAt  *time.Time
+}
+
+// MfaRecoveryCode é um código de recuperação de MFA de uso único, armazenado
+// apenas como hash.
+type MfaRecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"index;not null"`
+	User     User   `gorm:"constraint:OnDelete:CASCADE"`
+	CodeHash string `gorm:"uniqueIndex;size:64;not null"`
+	UsedAt   *time.Time
+}
+
+// permissionVersionID é a única linha de PermissionVersion.
+const permissionVersionID = 1
+
+// PermissionVersion é o contador global incrementado a cada alteração de
+// roles, permissões ou usuários; invalida o cache do PermissionResolver.
+type PermissionVersion struct {
+	ID      uint  `gorm:"primarykey"`
+	Version int64 `gorm:"not null;default:0"`
+}
+
+// AuditLog registra ações sensíveis, como o início de uma personificação e
+// cada requisição feita com o token resultante. Não tem chave estrangeira para
+// que o histórico sobreviva à remoção dos usuários.
+type AuditLog struct {
+	gorm.Model
+	ActorID uint   `gorm:"index;not null"` // quem realmente executou a ação
+	UserID  uint   `gorm:"index"`          // usuário afetado ou personificado
+	Action  string `gorm:"size:100;not null"`
+	Method  string `gorm:"size:10"`
+	Path    string `gorm:"size:255"`
+	IP      string `gorm:"size:64"`
+	Status  int
+}
+
+// PasswordHistory guarda os hashes de senhas anteriores, usados para impedir o
+// reuso conforme PasswordPolicy.History.
+type PasswordHistory struct {
+	gorm.Model
+	UserID uint   `gorm:"index;not null"`
+	User   User   `gorm:"constraint:OnDelete:CASCADE"`
+	Hash   string `gorm:"not null"`
+}
+
+// PasswordReset é um token de redefinição de senha de uso único, armazenado
+// apenas como hash.
+type PasswordReset struct {
+	gorm.Model
+	UserID    uint      `gorm:"index;not null"`
+	User      User      `gorm:"constraint:OnDelete:CASCADE"`
+	TokenHash string    `gorm:"uniqueIndex;size:64;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+// ApiKey é uma credencial de longa duração para integrações, armazenada
+// apenas como hash e restrita às permissões em Permissions.
+type ApiKey struct {
+	gorm.Model
+	Name        string       `gorm:"size:100;not null" validate:"required,min=3,max=100"`
+	Prefix      string       `gorm:"uniqueIndex;size:16;not null"`
+	KeyHash     string       `gorm:"size:64;not null"`
+	UserID      uint         `gorm:"index;not null"`
+	User        User         `gorm:"constraint:OnDelete:CASCADE"`
+	TenantID    uint         `gorm:"index;default:0"` // tenant ativo de quem criou a chave
+	Permissions []Permission `gorm:"many2many:api_keys_permissions"`
+	ExpiresAt   *time.Time
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+}
+
+// UserIdentity vincula um usuário a uma identidade de um provedor OIDC.
+type UserIdentity struct {
+	gorm.Model
+	Provider string `gorm:"uniqueIndex:idx_identity_provider_subject;size:50;not null"`
+	Subject  string `gorm:"uniqueIndex:idx_identity_provider_subject;size:255;not null"`
+	UserID   uint   `gorm:"index;not null"`
+	User     User   `gorm:"constraint:OnDelete:CASCADE"`
+	Email    string `gorm:"size:255"`
+}
+
+// OidcState guarda o state, o nonce e o code_verifier (PKCE) de um login OIDC
+// em andamento.
+type OidcState struct {
+	gorm.Model
+	State        string    `gorm:"uniqueIndex;size:64;not null"`
+	Provider     string    `gorm:"size:50;not null"`
+	Nonce        string    `gorm:"size:64;not null"`
+	CodeVerifier string    `gorm:"size:128;not null"`
+	ExpiresAt    time.Time `gorm:"index;not null"`
 }
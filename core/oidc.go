@@ -0,0 +1,560 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// OidcStateTTL é o tempo máximo entre o redirecionamento e o callback.
+	OidcStateTTL = 10 * time.Minute
+	// oidcCacheTTL é a validade do discovery e do JWKS em cache.
+	oidcCacheTTL = time.Hour
+)
+
+// OidcProvider configura um provedor OpenID Connect externo para login via
+// authorization code + PKCE.
+type OidcProvider struct {
+	Name          string // identificador usado em /auth/oidc/:provider
+	Issuer        string // URL do issuer; endpoints obtidos via discovery
+	ClientID      string
+	ClientSecret  string            // opcional para clientes públicos
+	RedirectURL   string            // URL pública de /auth/oidc/:provider/callback
+	Scopes        []string          // padrão: openid, email, profile
+	RoleClaim     string            // claim com os grupos do usuário, ex.: "groups"
+	RoleMapping   map[string]string // valor do RoleClaim -> nome da Role
+	AutoProvision bool              // cria o usuário no primeiro login
+	LinkByEmail   bool              // vincula a conta local com o mesmo e-mail verificado
+	HTTPClient    *http.Client      // opcional, padrão http.DefaultClient
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type oidcTokenResponse struct {
+	IDToken string `json:"id_token"`
+	Error   string `json:"error"`
+}
+
+// oidcClient mantém em cache o discovery e as chaves de um provedor.
+type oidcClient struct {
+	provider OidcProvider
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      []JwtKey
+	fetchedAt time.Time
+}
+
+type oidcIdentity struct {
+	Subject   string
+	Email     string
+	Verified  bool
+	Username  string
+	FirstName string
+	LastName  string
+	Roles     []string
+}
+
+func newOidcClients(providers []OidcProvider) map[string]*oidcClient {
+	clients := make(map[string]*oidcClient)
+	for _, provider := range providers {
+		clients[provider.Name] = &oidcClient{provider: provider}
+	}
+	return clients
+}
+
+func validateOidcProviders(providers []OidcProvider) error {
+	seen := make(map[string]bool)
+	for _, provider := range providers {
+		if provider.Name == "" || provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			return fmt.Errorf("oidc provider requires Name, Issuer, ClientID and RedirectURL")
+		}
+		if seen[provider.Name] {
+			return fmt.Errorf("duplicated oidc provider '%s'", provider.Name)
+		}
+		seen[provider.Name] = true
+	}
+	return nil
+}
+
+// OidcAuthURL inicia o login no provedor: registra state, nonce e o
+// code_verifier (PKCE) e retorna a URL de autorização.
+func (s *Service) OidcAuthURL(ctx context.Context, name string) (string, error) {
+	client, ok := s.oidc[name]
+	if !ok {
+		return "", fmt.Errorf("unknown oidc provider '%s'", name)
+	}
+	discovery, err := client.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().In(s.TimeUCT)
+	if err := s.GormStore.Where("expires_at < ?", now).Delete(&OidcState{}).Error; err != nil {
+		return "", fmt.Errorf("failed to purge oidc states: %s", err.Error())
+	}
+	if err := s.GormStore.Create(&OidcState{
+		State:        state,
+		Provider:     name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(OidcStateTTL),
+	}).Error; err != nil {
+		return "", fmt.Errorf("failed to store oidc state: %s", err.Error())
+	}
+
+	scopes := client.provider.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", client.provider.ClientID)
+	query.Set("redirect_uri", client.provider.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %s", err.Error())
+	}
+	current := authURL.Query()
+	for key, values := range query {
+		current[key] = values
+	}
+	authURL.RawQuery = current.Encode()
+	return authURL.String(), nil
+}
+
+// OidcLogin conclui o login: troca o code pelo ID token, valida-o com o JWKS
+// do provedor e retorna o usuário vinculado (ou provisionado).
+func (s *Service) OidcLogin(ctx context.Context, name string, req *OidcCallback) (*User, error) {
+	client, ok := s.oidc[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown oidc provider '%s'", name)
+	}
+	if req.Error != "" {
+		return nil, fmt.Errorf("oidc provider returned error: %s", req.Error)
+	}
+
+	state, err := s.consumeOidcState(name, req.State)
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, err := client.exchange(ctx, req.Code, state.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	identity, err := client.verify(ctx, rawIDToken, state.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveOidcUser(name, client.provider, identity)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, fmt.Errorf("failed to login: user is inactive")
+	}
+	if err := s.syncOidcRoles(user, client.provider, identity.Roles); err != nil {
+		return nil, err
+	}
+	return s.GetUserByID(user.ID)
+}
+
+// consumeOidcState busca o state e o apaga com uma exclusão condicional: de
+// dois callbacks concorrentes com o mesmo state, só o que apagar a linha segue.
+func (s *Service) consumeOidcState(name string, value string) (*OidcState, error) {
+	var state OidcState
+	if err := s.GormStore.Where("state = ? AND provider = ?", value, name).First(&state).Error; err != nil {
+		return nil, fmt.Errorf("invalid oidc state")
+	}
+	now := time.Now().In(s.TimeUCT)
+	if now.After(state.ExpiresAt) {
+		return nil, fmt.Errorf("oidc state expired")
+	}
+	result := s.GormStore.
+		Where("state = ? AND expires_at > ?", value, now).
+		Delete(&OidcState{})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to consume oidc state: %s", result.Error.Error())
+	}
+	if result.RowsAffected != 1 {
+		return nil, fmt.Errorf("invalid oidc state")
+	}
+	return &state, nil
+}
+
+// resolveOidcUser encontra o usuário vinculado à identidade externa. Sem
+// vínculo, associa pelo e-mail verificado (se o provedor permitir) ou
+// provisiona um novo usuário.
+func (s *Service) resolveOidcUser(name string, provider OidcProvider, identity *oidcIdentity) (*User, error) {
+	var link UserIdentity
+	err := s.GormStore.Where("provider = ? AND subject = ?", name, identity.Subject).First(&link).Error
+	if err == nil {
+		return s.GetUserByID(link.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+
+	var user User
+	found := false
+	if identity.Email != "" && identity.Verified {
+		err := s.GormStore.Where("email = ?", identity.Email).First(&user).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to query database: %w", err)
+		}
+		found = err == nil
+	}
+	// Vincular pelo e-mail confia no provedor para a posse da conta local:
+	// exige opt-in e nunca vale para o superusuário
+	if found && (!provider.LinkByEmail || user.IsSuperUser) {
+		return nil, fmt.Errorf("no account linked to this %s identity", name)
+	}
+	if !found {
+		if !provider.AutoProvision {
+			return nil, fmt.Errorf("no account linked to this %s identity", name)
+		}
+		if identity.Email == "" || !identity.Verified {
+			return nil, fmt.Errorf("oidc identity has no verified email")
+		}
+		provisioned, err := s.provisionOidcUser(identity)
+		if err != nil {
+			return nil, err
+		}
+		user = *provisioned
+	}
+
+	if err := s.GormStore.Create(&UserIdentity{
+		Provider: name,
+		Subject:  identity.Subject,
+		UserID:   user.ID,
+		Email:    identity.Email,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to link identity: %s", err.Error())
+	}
+	return &user, nil
+}
+
+func (s *Service) provisionOidcUser(identity *oidcIdentity) (*User, error) {
+	// Senha aleatória descartada: o login local fica indisponível até uma
+	// redefinição de senha
+	password, err := randomURLToken(32)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("crypting password failed: %s", err.Error())
+	}
+
+	username := identity.Username
+	if username == "" {
+		username, _, _ = strings.Cut(identity.Email, "@")
+	}
+	username = truncate(username, 40)
+	var count int64
+	if err := s.GormStore.Model(&User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+	if count > 0 {
+		suffix, err := randomURLToken(4)
+		if err != nil {
+			return nil, err
+		}
+		username = username + "-" + strings.ToLower(suffix[:6])
+	}
+
+	firstName := identity.FirstName
+	if firstName == "" {
+		firstName = username
+	}
+	now := time.Now().In(s.TimeUCT)
+	user := &User{
+		FirstName:         truncate(firstName, 50),
+		LastName:          truncate(identity.LastName, 50),
+		Username:          username,
+		Email:             identity.Email,
+		Password:          hashedPassword,
+		Active:            true,
+		PasswordChangedAt: &now,
+	}
+	if err := s.GormStore.Create(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to create user: %s", err.Error())
+	}
+	return user, nil
+}
+
+// syncOidcRoles aplica o RoleMapping: as roles mapeadas passam a refletir os
+// grupos do provedor; roles fora do mapeamento não são alteradas.
+func (s *Service) syncOidcRoles(user *User, provider OidcProvider, groups []string) error {
+	if provider.RoleClaim == "" || len(provider.RoleMapping) == 0 {
+		return nil
+	}
+	var managed, wanted []string
+	for group, role := range provider.RoleMapping {
+		managed = append(managed, role)
+		if slices.Contains(groups, group) {
+			wanted = append(wanted, role)
+		}
+	}
+
+	var roles []Role
+	for _, role := range user.Roles {
+		if !slices.Contains(managed, role.Name) {
+			roles = append(roles, role)
+		}
+	}
+	if len(wanted) > 0 {
+		var mapped []Role
+		if err := s.GormStore.Where("name IN ? AND tenant_id = ?", wanted, 0).Find(&mapped).Error; err != nil {
+			return fmt.Errorf("failed to fetch roles: %s", err.Error())
+		}
+		roles = append(roles, mapped...)
+	}
+	if sameRoles(user.Roles, roles) {
+		return nil
+	}
+	if err := s.GormStore.Model(user).Association("Roles").Replace(roles); err != nil {
+		return fmt.Errorf("failed to set roles for user: %v", err)
+	}
+	return s.Permissions.Bump()
+}
+
+func (c *oidcClient) httpClient() *http.Client {
+	if c.provider.HTTPClient != nil {
+		return c.provider.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *oidcClient) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.discovery != nil && time.Since(c.fetchedAt) < oidcCacheTTL {
+		return c.discovery, nil
+	}
+
+	endpoint := strings.TrimSuffix(c.provider.Issuer, "/") + "/.well-known/openid-configuration"
+	var discovery oidcDiscovery
+	if err := c.getJSON(ctx, endpoint, &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery failed: %s", err.Error())
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(c.provider.Issuer, "/") {
+		return nil, fmt.Errorf("oidc discovery issuer mismatch: %s", discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksURI == "" {
+		return nil, fmt.Errorf("oidc discovery is incomplete")
+	}
+	c.discovery = &discovery
+	c.keys = nil
+	c.fetchedAt = time.Now()
+	return c.discovery, nil
+}
+
+// getKeys retorna o JWKS em cache; force recarrega (ex.: kid desconhecido
+// após rotação no provedor).
+func (c *oidcClient) getKeys(ctx context.Context, force bool) ([]JwtKey, error) {
+	discovery, err := c.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.keys != nil && !force {
+		return c.keys, nil
+	}
+
+	var jwks Jwks
+	if err := c.getJSON(ctx, discovery.JwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("oidc jwks fetch failed: %s", err.Error())
+	}
+	var keys []JwtKey
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		publicKey, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys = append(keys, JwtKey{Kid: jwk.Kid, PublicKey: publicKey})
+	}
+	c.keys = keys
+	return keys, nil
+}
+
+func (c *oidcClient) exchange(ctx context.Context, code, verifier string) (string, error) {
+	discovery, err := c.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.provider.RedirectURL)
+	form.Set("client_id", c.provider.ClientID)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %s", err.Error())
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.provider.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(c.provider.ClientID), url.QueryEscape(c.provider.ClientSecret))
+	}
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oidc token request failed: %s", err.Error())
+	}
+	defer res.Body.Close()
+	var token oidcTokenResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid oidc token response: %s", err.Error())
+	}
+	if res.StatusCode != http.StatusOK || token.IDToken == "" {
+		return "", fmt.Errorf("oidc token request failed: %s %s", res.Status, token.Error)
+	}
+	return token.IDToken, nil
+}
+
+// verify valida o ID token (assinatura pelo JWKS, issuer, audience, validade
+// e nonce) e extrai a identidade.
+func (c *oidcClient) verify(ctx context.Context, rawIDToken, nonce string) (*oidcIdentity, error) {
+	discovery, err := c.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keyfunc := func(t *jwt.Token) (any, error) {
+		keys, err := c.getKeys(ctx, false)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := t.Header["kid"]; !ok && len(keys) == 1 {
+			t.Header["kid"] = keys[0].Kid
+		}
+		key, err := lookupJwtKey(keys, t)
+		if err == nil {
+			return key, nil
+		}
+		// Kid desconhecido: o provedor pode ter rotacionado as chaves
+		if keys, err = c.getKeys(ctx, true); err != nil {
+			return nil, err
+		}
+		return lookupJwtKey(keys, t)
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(
+		rawIDToken,
+		claims,
+		keyfunc,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(c.provider.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %s", classifyJwtError(err).Error())
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, fmt.Errorf("invalid id token: nonce mismatch")
+	}
+
+	identity := &oidcIdentity{}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Verified, _ = claims["email_verified"].(bool)
+	identity.Username, _ = claims["preferred_username"].(string)
+	identity.FirstName, _ = claims["given_name"].(string)
+	identity.LastName, _ = claims["family_name"].(string)
+	if identity.FirstName == "" {
+		identity.FirstName, _ = claims["name"].(string)
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("invalid id token: missing sub")
+	}
+	if c.provider.RoleClaim != "" {
+		switch value := claims[c.provider.RoleClaim].(type) {
+		case string:
+			identity.Roles = strings.Fields(value)
+		case []any:
+			for _, item := range value {
+				if role, ok := item.(string); ok {
+					identity.Roles = append(identity.Roles, role)
+				}
+			}
+		}
+	}
+	return identity, nil
+}
+
+func (c *oidcClient) getJSON(ctx context.Context, endpoint string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(target)
+}
+
+func randomURLToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %s", err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,364 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testOidcClientID = "test-client"
+	testOidcCode     = "test-code"
+)
+
+// mockIdp é um provedor OpenID Connect em processo: discovery, JWKS e token
+// endpoint com verificação de PKCE.
+type mockIdp struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey // publicada no JWKS
+	signer *rsa.PrivateKey // usada para assinar o ID token
+	issuer string          // issuer informado no discovery
+
+	mu         sync.Mutex
+	authorized map[string]url.Values // code -> parâmetros de /authorize
+	claims     jwt.MapClaims         // claims extras ou substituídos no ID token
+}
+
+func newMockIdp(t *testing.T) *mockIdp {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdp{t: t, key: key, signer: key, authorized: make(map[string]url.Values), claims: jwt.MapClaims{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	idp.issuer = idp.server.URL
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdp) provider() OidcProvider {
+	return OidcProvider{
+		Name:          "mock",
+		Issuer:        idp.server.URL,
+		ClientID:      testOidcClientID,
+		RedirectURL:   "http://app.test/auth/oidc/mock/callback",
+		AutoProvision: true,
+	}
+}
+
+func (idp *mockIdp) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 idp.issuer,
+		"authorization_endpoint": idp.server.URL + "/authorize",
+		"token_endpoint":         idp.server.URL + "/token",
+		"jwks_uri":               idp.server.URL + "/jwks",
+	})
+}
+
+func (idp *mockIdp) jwks(w http.ResponseWriter, r *http.Request) {
+	jwk, err := JwtKey{Kid: "idp-key", PublicKey: &idp.key.PublicKey}.Jwk()
+	if err != nil {
+		idp.t.Error(err)
+	}
+	json.NewEncoder(w).Encode(Jwks{Keys: []Jwk{jwk}})
+}
+
+func (idp *mockIdp) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	idp.mu.Lock()
+	params, ok := idp.authorized[r.Form.Get("code")]
+	delete(idp.authorized, r.Form.Get("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || r.Form.Get("grant_type") != "authorization_code" ||
+		params.Get("code_challenge_method") != "S256" ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != params.Get("code_challenge") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":                idp.server.URL,
+		"aud":                testOidcClientID,
+		"sub":                "ext-carol",
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              params.Get("nonce"),
+		"email":              "carol@example.com",
+		"email_verified":     true,
+		"preferred_username": "carol",
+	}
+	for name, value := range idp.claims {
+		claims[name] = value
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "idp-key"
+	signed, err := token.SignedString(idp.signer)
+	if err != nil {
+		idp.t.Error(err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+}
+
+// authorize inicia o login pela aplicação e simula a aprovação no provedor,
+// retornando os parâmetros recebidos em /authorize.
+func (idp *mockIdp) authorize(a *testApp) url.Values {
+	a.t.Helper()
+	res, err := a.app.Test(httptest.NewRequest("GET", "/auth/oidc/mock/login", nil), -1)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusFound {
+		a.t.Fatalf("oidc login: %d", res.StatusCode)
+	}
+	location, err := url.Parse(res.Header.Get(fiber.HeaderLocation))
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	params := location.Query()
+	idp.mu.Lock()
+	idp.authorized[testOidcCode] = params
+	idp.mu.Unlock()
+	return params
+}
+
+func callbackPath(code, state string) string {
+	query := url.Values{}
+	if code != "" {
+		query.Set("code", code)
+	}
+	query.Set("state", state)
+	return "/auth/oidc/mock/callback?" + query.Encode()
+}
+
+func TestOidcLogin(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		linkByEmail bool
+		setup       func(a *testApp, idp *mockIdp)
+		callback    func(params url.Values) string
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:       "provisions user",
+			wantStatus: fiber.StatusOK,
+			wantBody:   "access_token",
+		},
+		{
+			name:        "links verified email",
+			linkByEmail: true,
+			setup: func(a *testApp, idp *mockIdp) {
+				a.createUser("carol")
+			},
+			wantStatus: fiber.StatusOK,
+			wantBody:   "access_token",
+		},
+		{
+			name: "existing email without opt-in",
+			setup: func(a *testApp, idp *mockIdp) {
+				a.createUser("carol")
+			},
+			wantStatus: fiber.StatusUnauthorized,
+			wantBody:   "no account linked",
+		},
+		{
+			name:        "superuser email is never linked",
+			linkByEmail: true,
+			setup: func(a *testApp, idp *mockIdp) {
+				idp.claims["email"] = a.superUser().Email
+			},
+			wantStatus: fiber.StatusUnauthorized,
+			wantBody:   "no account linked",
+		},
+		{
+			name:        "linked mfa account requires second factor",
+			linkByEmail: true,
+			setup: func(a *testApp, idp *mockIdp) {
+				user := a.createUser("carol")
+				a.r.GormStore.Model(user).Updates(map[string]any{"mfa_enabled": true, "mfa_secret": testMfaSecret})
+			},
+			wantStatus: fiber.StatusOK,
+			wantBody:   `"mfa_required":true`,
+		},
+		{
+			name: "provider error",
+			callback: func(params url.Values) string {
+				return callbackPath("", params.Get("state")) + "&error=access_denied"
+			},
+			wantStatus: fiber.StatusUnauthorized,
+			wantBody:   "access_denied",
+		},
+		{
+			name: "unknown state",
+			callback: func(params url.Values) string {
+				return callbackPath(testOidcCode, params.Get("state")+"x")
+			},
+			wantStatus: fiber.StatusUnauthorized,
+			wantBody:   "invalid oidc state",
+		},
+		{
+			name: "pkce verifier mismatch",
+			setup: func(a *testApp, idp *mockIdp) {
+				idp.mu.Lock()
+				defer idp.mu.Unlock()
+				idp.authorized[testOidcCode].Set("code_challenge", "tampered")
+			},
+			wantStatus: fiber.StatusUnauthorized,
+			wantBody:   "invalid_grant",
+		},
+		{
+			name: "nonce mismatch",
+			setup: func(a *testApp, idp *mockIdp) {
+				idp.claims["nonce"] = "other"
+			},
+			wantStatus: fiber.StatusUnauthorized,
+			wantBody:   "nonce mismatch",
+		},
+		{
+			name: "wrong audience",
+			setup: func(a *testApp, idp *mockIdp) {
+				idp.claims["aud"] = "other-client"
+			},
+			wantStatus: fiber.StatusUnauthorized,
+			wantBody:   "invalid id token",
+		},
+		{
+			name: "expired id token",
+			setup: func(a *testApp, idp *mockIdp) {
+				idp.claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			},
+			wantStatus: fiber.StatusUnauthorized,
+			wantBody:   "invalid id token",
+		},
+		{
+			name: "signature from unpublished key",
+			setup: func(a *testApp, idp *mockIdp) {
+				idp.signer = otherKey
+			},
+			wantStatus: fiber.StatusUnauthorized,
+			wantBody:   "invalid id token",
+		},
+		{
+			name: "unverified email without link",
+			setup: func(a *testApp, idp *mockIdp) {
+				a.createUser("carol")
+				idp.claims["email_verified"] = false
+			},
+			wantStatus: fiber.StatusUnauthorized,
+			wantBody:   "no verified email",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdp(t)
+			provider := idp.provider()
+			provider.LinkByEmail = tt.linkByEmail
+			a := newTestApp(t, func(config *AppConfig) {
+				config.Oidc = []OidcProvider{provider}
+			})
+			params := idp.authorize(a)
+			if tt.setup != nil {
+				tt.setup(a, idp)
+			}
+			path := callbackPath(testOidcCode, params.Get("state"))
+			if tt.callback != nil {
+				path = tt.callback(params)
+			}
+			status, _, raw := a.do("GET", path, "", nil)
+			if status != tt.wantStatus || !strings.Contains(raw, tt.wantBody) {
+				t.Fatalf("got %d %s, want %d containing %q", status, raw, tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
+
+// TestOidcErrorDoesNotLeak garante que um callback com erro não contamina os
+// callbacks seguintes.
+func TestOidcErrorDoesNotLeak(t *testing.T) {
+	idp := newMockIdp(t)
+	a := newTestApp(t, func(config *AppConfig) {
+		config.Oidc = []OidcProvider{idp.provider()}
+	})
+
+	params := idp.authorize(a)
+	if status, _, raw := a.do("GET", callbackPath("", params.Get("state"))+"&error=access_denied", "", nil); status != fiber.StatusUnauthorized {
+		t.Fatalf("error callback: %d %s", status, raw)
+	}
+	params = idp.authorize(a)
+	if status, _, raw := a.do("GET", callbackPath(testOidcCode, params.Get("state")), "", nil); status != fiber.StatusOK {
+		t.Fatalf("callback after error: %d %s", status, raw)
+	}
+	// O state é de uso único
+	if status, _, raw := a.do("GET", callbackPath(testOidcCode, params.Get("state")), "", nil); status != fiber.StatusUnauthorized {
+		t.Fatalf("state replay: %d %s", status, raw)
+	}
+}
+
+func TestOidcDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newMockIdp(t)
+	idp.issuer = "https://evil.example.com"
+	a := newTestApp(t, func(config *AppConfig) {
+		config.Oidc = []OidcProvider{idp.provider()}
+	})
+	if status, _, raw := a.do("GET", "/auth/oidc/mock/login", "", nil); status != fiber.StatusBadRequest || !strings.Contains(raw, "issuer mismatch") {
+		t.Fatalf("got %d %s", status, raw)
+	}
+}
+
+// TestOidcStateConcurrentCallbacks garante que, entre callbacks simultâneos
+// com o mesmo state, só um o consome.
+func TestOidcStateConcurrentCallbacks(t *testing.T) {
+	const callbacks = 8
+	idp := newMockIdp(t)
+	a := newTestApp(t, func(config *AppConfig) {
+		config.Oidc = []OidcProvider{idp.provider()}
+	})
+	state := idp.authorize(a).Get("state")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	consumed := 0
+	for range callbacks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := a.r.Controller.Service.consumeOidcState("mock", state); err == nil {
+				mu.Lock()
+				consumed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if consumed != 1 {
+		t.Fatalf("state consumed %d times", consumed)
+	}
+}
@@ -0,0 +1,366 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultPasswordResetTTL é a validade padrão do token de redefinição de senha.
+const DefaultPasswordResetTTL = 30 * time.Minute
+
+// DefaultPasswordChangeTTL é a validade padrão do token emitido no login para
+// trocar uma senha expirada ou provisória.
+const DefaultPasswordChangeTTL = 5 * time.Minute
+
+// ForgotPassword envia um token de redefinição para o e-mail do usuário. A
+// resposta é a mesma exista ou não a conta, para não revelar e-mails
+// cadastrados: a busca, a gravação do token e o envio rodam em segundo plano,
+// e o tempo de resposta não depende da conta. Falhas vão apenas para o log.
+func (s *Service) ForgotPassword(ctx context.Context, req *ForgotPassword) error {
+	if s.Mailer == nil {
+		return fmt.Errorf("password reset is not configured")
+	}
+	// O contexto da requisição é reciclado pelo fasthttp ao responder
+	email := req.Email
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		if err := s.sendPasswordReset(context.Background(), email); err != nil {
+			log.Printf("failed to send password reset: %v", err)
+		}
+	}()
+	return nil
+}
+
+// WaitBackground aguarda as tarefas em segundo plano, como os e-mails de
+// redefinição de senha; use ao desligar a aplicação.
+func (s *Service) WaitBackground() {
+	s.background.Wait()
+}
+
+func (s *Service) sendPasswordReset(ctx context.Context, email string) error {
+	var user User
+	if err := s.GormStore.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to query database: %w", err)
+	}
+	if !user.Active {
+		return nil
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("failed to generate reset token: %s", err.Error())
+	}
+	token := hex.EncodeToString(raw)
+
+	now := time.Now().In(s.TimeUCT)
+	ttl := s.Auth.PasswordResetTtl
+	if ttl <= 0 {
+		ttl = DefaultPasswordResetTTL
+	}
+
+	// Apenas o token mais recente continua válido
+	if err := s.GormStore.Model(&PasswordReset{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Update("used_at", now).Error; err != nil {
+		return fmt.Errorf("failed to invalidate reset tokens: %s", err.Error())
+	}
+	if err := s.GormStore.Create(&PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hashResetToken(token),
+		ExpiresAt: now.Add(ttl),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to store reset token: %s", err.Error())
+	}
+
+	mail := Mail{
+		To:      user.Email,
+		Subject: fmt.Sprintf("%s: password reset", s.Jwt.AppName),
+		Body:    passwordResetBody(s.Auth.PasswordResetURL, token, ttl),
+	}
+	if err := s.Mailer.Send(ctx, mail); err != nil {
+		return fmt.Errorf("failed to send password reset mail to user %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword troca a senha usando um token de redefinição válido, consome o
+// token e encerra todas as sessões do usuário.
+func (s *Service) ResetPassword(req *ResetPassword) error {
+	var reset PasswordReset
+	if err := s.GormStore.
+		Where("token_hash = ? AND used_at IS NULL", hashResetToken(req.Token)).
+		First(&reset).Error; err != nil {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+	now := time.Now().In(s.TimeUCT)
+	if now.After(reset.ExpiresAt) {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	user, err := s.GetUserByID(reset.UserID)
+	if err != nil {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+	if err := s.ValidatePassword(req.Password, user); err != nil {
+		return err
+	}
+	if err := s.checkPasswordReuse(user, req.Password); err != nil {
+		return err
+	}
+	hashedPassword, err := s.HashPassword(req.Password)
+	if err != nil {
+		return fmt.Errorf("crypting password failed: %s", err.Error())
+	}
+
+	err = s.GormStore.Transaction(func(tx *gorm.DB) error {
+		// Consome o token de forma atômica
+		result := tx.Model(&PasswordReset{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("invalid or expired reset token")
+		}
+		return s.savePassword(tx, user, hashedPassword)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reset password: %s", err.Error())
+	}
+
+	return s.revokeAllTokens(reset.UserID)
+}
+
+// ChangePassword troca a senha do usuário autenticado após conferir a senha
+// atual. Opcionalmente encerra as demais sessões, mantendo a atual.
+func (s *Service) ChangePassword(claims *JwtClaims, req *ChangePassword) error {
+	user, err := s.GetUserByID(claims.Sub)
+	if err != nil {
+		return err
+	}
+	if !s.CheckPassword(req.CurrentPassword, user.Password) {
+		return fmt.Errorf("current password is incorrect")
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return fmt.Errorf("new password must be different from the current password")
+	}
+	if err := s.ValidatePassword(req.NewPassword, user); err != nil {
+		return err
+	}
+	if err := s.checkPasswordReuse(user, req.NewPassword); err != nil {
+		return err
+	}
+	hashedPassword, err := s.HashPassword(req.NewPassword)
+	if err != nil {
+		return fmt.Errorf("crypting password failed: %s", err.Error())
+	}
+
+	if err := s.GormStore.Transaction(func(tx *gorm.DB) error {
+		return s.savePassword(tx, user, hashedPassword)
+	}); err != nil {
+		return fmt.Errorf("failed to change password: %s", err.Error())
+	}
+
+	if req.RevokeOtherSessions {
+		return s.revokeOtherSessions(user.ID, claims.Sid)
+	}
+	return nil
+}
+
+// Motivos para exigir a troca de senha no login.
+const (
+	PasswordChangeReasonRequired = "required" // senha provisória, definida por um administrador
+	PasswordChangeReasonExpired  = "expired"  // senha mais antiga que PasswordPolicy.MaxAge
+)
+
+// PasswordChangeReason indica se o usuário precisa trocar a senha antes de
+// receber uma sessão; vazio quando não precisa.
+func (s *Service) PasswordChangeReason(user *User) string {
+	if user.MustChangePassword {
+		return PasswordChangeReasonRequired
+	}
+	maxAge := s.passwordPolicy().MaxAge
+	if maxAge <= 0 {
+		return ""
+	}
+	changedAt := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changedAt = *user.PasswordChangedAt
+	}
+	if time.Since(changedAt) > maxAge {
+		return PasswordChangeReasonExpired
+	}
+	return ""
+}
+
+// PasswordChangeChallenge emite o token restrito que permite apenas trocar a
+// senha em /auth/password/expired.
+func (s *Service) PasswordChangeChallenge(user *User, reason string) (*PasswordChangeChallenge, error) {
+	ttl := s.Auth.PasswordChangeTtl
+	if ttl <= 0 {
+		ttl = DefaultPasswordChangeTTL
+	}
+	token, err := GenerateToken(&GenToken{
+		Id:        user.ID,
+		Type:      TokenPasswordChange,
+		AppName:   s.Jwt.AppName,
+		Audience:  []string{s.Jwt.GetAudience()},
+		TimeZone:  s.Jwt.TimeZone,
+		JwtSecret: s.Jwt.JwtSecret,
+		Key:       s.Jwt.SigningKey(),
+		Ttl:       ttl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PasswordChangeChallenge{
+		PasswordChangeRequired: true,
+		PasswordChangeToken:    token,
+		Reason:                 reason,
+	}, nil
+}
+
+// ChangeExpiredPassword troca a senha usando o token restrito emitido no login
+// e retorna o usuário para que a sessão seja iniciada. O token é de uso único.
+func (s *Service) ChangeExpiredPassword(req *ChangeExpiredPassword) (*User, error) {
+	claims, err := s.Jwt.Verifier().Verify(req.PasswordChangeToken, TokenPasswordChange)
+	if err != nil {
+		return nil, fmt.Errorf("invalid password change token: %s", err.Error())
+	}
+	revoked, err := s.Revocations.IsRevoked(claims)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, fmt.Errorf("invalid password change token: %s", ErrTokenRevoked.Error())
+	}
+
+	user, err := s.GetUserByID(claims.Sub)
+	if err != nil || !user.Active {
+		return nil, fmt.Errorf("invalid password change token")
+	}
+	if s.CheckPassword(req.NewPassword, user.Password) {
+		return nil, fmt.Errorf("new password must be different from the current password")
+	}
+	if err := s.ValidatePassword(req.NewPassword, user); err != nil {
+		return nil, err
+	}
+	if err := s.checkPasswordReuse(user, req.NewPassword); err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.HashPassword(req.NewPassword)
+	if err != nil {
+		return nil, fmt.Errorf("crypting password failed: %s", err.Error())
+	}
+
+	if err := s.GormStore.Transaction(func(tx *gorm.DB) error {
+		return s.savePassword(tx, user, hashedPassword)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to change password: %s", err.Error())
+	}
+	expiresAt := time.Unix(int64(claims.Exp), 0)
+	if err := s.Revocations.RevokeToken(claims.ID, claims.Sub, expiresAt); err != nil {
+		return nil, err
+	}
+	return s.GetUserByID(user.ID)
+}
+
+// checkPasswordReuse rejeita a senha atual e as anteriores guardadas no
+// histórico, conforme PasswordPolicy.History.
+func (s *Service) checkPasswordReuse(user *User, password string) error {
+	size := s.passwordPolicy().History
+	if size <= 0 {
+		return nil
+	}
+	hashes := []string{user.Password}
+	if size > 1 {
+		var history []PasswordHistory
+		if err := s.GormStore.
+			Where("user_id = ?", user.ID).
+			Order("id DESC").
+			Limit(size - 1).
+			Find(&history).Error; err != nil {
+			return fmt.Errorf("failed to query database: %w", err)
+		}
+		for _, item := range history {
+			hashes = append(hashes, item.Hash)
+		}
+	}
+	for _, hash := range hashes {
+		if s.CheckPassword(password, hash) {
+			return &PasswordPolicyError{
+				Message: "password does not meet the policy",
+				Violations: []PasswordViolation{{
+					Code:    PasswordReused,
+					Message: fmt.Sprintf("password must differ from the last %d passwords", size),
+					Params:  map[string]any{"history": size},
+				}},
+			}
+		}
+	}
+	return nil
+}
+
+// savePassword grava o novo hash, move o anterior para o histórico e limpa a
+// exigência de troca.
+func (s *Service) savePassword(tx *gorm.DB, user *User, hashedPassword string) error {
+	if size := s.passwordPolicy().History; size > 1 {
+		if err := tx.Create(&PasswordHistory{UserID: user.ID, Hash: user.Password}).Error; err != nil {
+			return err
+		}
+		// Mantém apenas as entradas necessárias para a verificação
+		var keep []uint
+		if err := tx.Model(&PasswordHistory{}).
+			Where("user_id = ?", user.ID).
+			Order("id DESC").
+			Limit(size-1).
+			Pluck("id", &keep).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().
+			Where("user_id = ? AND id NOT IN ?", user.ID, keep).
+			Delete(&PasswordHistory{}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(user).Updates(map[string]any{
+		"password":             hashedPassword,
+		"password_changed_at":  time.Now().In(s.TimeUCT),
+		"must_change_password": false,
+	}).Error
+}
+
+func passwordResetBody(resetURL, token string, ttl time.Duration) string {
+	link := token
+	if resetURL != "" {
+		if u, err := url.Parse(resetURL); err == nil {
+			query := u.Query()
+			query.Set("token", token)
+			u.RawQuery = query.Encode()
+			link = u.String()
+		}
+	}
+	return fmt.Sprintf(
+		"A password reset was requested for your account.\n\n%s\n\nThis link expires in %s. If you did not request it, ignore this message.\n",
+		link, ttl,
+	)
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
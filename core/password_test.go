@@ -0,0 +1,203 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestChangeExpiredPasswordNoTokenReuse reproduz a troca de senha sem token
+// aproveitando o token de quem fez a requisição anterior.
+func TestChangeExpiredPasswordNoTokenReuse(t *testing.T) {
+	a := newTestApp(t)
+	victim := a.createUser("victim")
+	a.r.GormStore.Model(victim).Update("must_change_password", true)
+
+	status, data, raw := a.do("POST", "/auth/login", "", map[string]any{"username": "victim", "password": testPassword})
+	if status != fiber.StatusOK || data["password_change_token"] == nil {
+		t.Fatalf("login: %d %s", status, raw)
+	}
+	token := data["password_change_token"].(string)
+
+	// A vítima envia uma senha fraca: o token não é consumido
+	if status, _, raw := a.do("POST", "/auth/password/expired", "", map[string]any{"password_change_token": token, "new_password": "weak"}); status != fiber.StatusBadRequest {
+		t.Fatalf("weak password: %d %s", status, raw)
+	}
+	if status, _, raw := a.do("POST", "/auth/password/expired", "", map[string]any{"new_password": "Attacker!1"}); status != fiber.StatusBadRequest {
+		t.Fatalf("request without token: %d %s", status, raw)
+	}
+	a.login("victim", testPassword)
+}
+
+// TestChangePasswordRevokeFlagNotShared garante que revoke_other_sessions vale
+// só para a requisição que o enviou: quem omite a flag mantém as demais
+// sessões, mesmo logo após outro usuário pedir a revogação.
+func TestChangePasswordRevokeFlagNotShared(t *testing.T) {
+	a := newTestApp(t)
+	steps := []struct {
+		username    string
+		revoke      any // nil omite a flag
+		wantSession int
+	}{
+		{"alice", true, fiber.StatusUnauthorized},
+		{"bob", nil, fiber.StatusOK},
+	}
+	for _, step := range steps {
+		a.createUser(step.username)
+		other, _ := a.login(step.username, testPassword)
+		current, _ := a.login(step.username, testPassword)
+
+		body := map[string]any{"current_password": testPassword, "new_password": "New@Pass123"}
+		if step.revoke != nil {
+			body["revoke_other_sessions"] = step.revoke
+		}
+		if status, _, raw := a.do("POST", "/auth/password/change", current, body); status != fiber.StatusNoContent {
+			t.Fatalf("%s change: %d %s", step.username, status, raw)
+		}
+		if status, _, raw := a.do("GET", "/auth/me", other, nil); status != step.wantSession {
+			t.Fatalf("%s other session: %d %s", step.username, status, raw)
+		}
+	}
+}
+
+// mailerFunc adapta uma função ao Mailer.
+type mailerFunc func(ctx context.Context, mail Mail) error
+
+func (f mailerFunc) Send(ctx context.Context, mail Mail) error {
+	return f(ctx, mail)
+}
+
+// TestForgotPasswordSameResponse garante que a resposta não revela se a conta
+// existe, nem quando o envio do e-mail falha.
+func TestForgotPasswordSameResponse(t *testing.T) {
+	cases := []struct {
+		name     string
+		email    string
+		mailErr  error
+		wantSent int
+	}{
+		{"existing account", "bob@example.com", nil, 1},
+		{"existing account, mail failure", "bob@example.com", errors.New("smtp down"), 1},
+		{"unknown account", "ghost@example.com", nil, 0},
+		{"inactive account", "carol@example.com", nil, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sent := 0
+			a := newTestApp(t, func(c *AppConfig) {
+				c.Mailer = mailerFunc(func(ctx context.Context, mail Mail) error {
+					sent++
+					return tc.mailErr
+				})
+			})
+			a.createUser("bob")
+			carol := a.createUser("carol")
+			a.r.GormStore.Model(carol).Update("active", false)
+
+			status, _, raw := a.do("POST", "/auth/password/forgot", "", map[string]any{"email": tc.email})
+			if status != fiber.StatusAccepted {
+				t.Fatalf("status %d: %s", status, raw)
+			}
+			a.r.Controller.Service.WaitBackground()
+			if sent != tc.wantSent {
+				t.Fatalf("sent %d mails, want %d", sent, tc.wantSent)
+			}
+		})
+	}
+}
+
+// TestForgotPasswordDoesNotWaitForMail garante que a resposta sai antes do
+// envio: um servidor de e-mail lento não pode denunciar que a conta existe.
+func TestForgotPasswordDoesNotWaitForMail(t *testing.T) {
+	release := make(chan struct{})
+	a := newTestApp(t, func(c *AppConfig) {
+		c.Mailer = mailerFunc(func(ctx context.Context, mail Mail) error {
+			<-release
+			return nil
+		})
+	})
+	a.createUser("bob")
+	defer a.r.Controller.Service.WaitBackground()
+	defer close(release)
+
+	done := make(chan int, 1)
+	go func() {
+		status, _, _ := a.do("POST", "/auth/password/forgot", "", map[string]any{"email": "bob@example.com"})
+		done <- status
+	}()
+	select {
+	case status := <-done:
+		if status != fiber.StatusAccepted {
+			t.Fatalf("status %d", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("response waited for the mail to be sent")
+	}
+}
+
+// TestResetPassword cobre o fluxo completo: o token enviado por e-mail troca a
+// senha uma única vez.
+func TestResetPassword(t *testing.T) {
+	var body string
+	a := newTestApp(t, func(c *AppConfig) {
+		c.Mailer = mailerFunc(func(ctx context.Context, mail Mail) error {
+			body = mail.Body
+			return nil
+		})
+	})
+	a.createUser("bob")
+	if status, _, raw := a.do("POST", "/auth/password/forgot", "", map[string]any{"email": "bob@example.com"}); status != fiber.StatusAccepted {
+		t.Fatalf("forgot: %d %s", status, raw)
+	}
+	a.r.Controller.Service.WaitBackground()
+	token := strings.TrimSpace(strings.Split(body, "\n")[2])
+
+	steps := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"unknown token", "nope", fiber.StatusBadRequest},
+		{"valid token", token, fiber.StatusNoContent},
+		{"token reused", token, fiber.StatusBadRequest},
+	}
+	for _, step := range steps {
+		status, _, raw := a.do("POST", "/auth/password/reset", "", map[string]any{"token": step.token, "password": "Reset@1234"})
+		if status != step.status {
+			t.Fatalf("%s: %d %s", step.name, status, raw)
+		}
+	}
+	a.login("bob", "Reset@1234")
+}
+
+func TestPasswordChangeChallengeTTL(t *testing.T) {
+	cases := []struct {
+		name string
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{"default", 0, DefaultPasswordChangeTTL},
+		{"configured", 2 * time.Minute, 2 * time.Minute},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t, func(c *AppConfig) { c.Auth.PasswordChangeTtl = tc.ttl })
+			bob := a.createUser("bob")
+			challenge, err := a.r.Controller.Service.PasswordChangeChallenge(bob, PasswordChangeReasonRequired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := a.r.Jwt.Verifier().Verify(challenge.PasswordChangeToken, TokenPasswordChange)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := time.Duration(int64(claims.Exp)-claims.IssuedAt.Unix()) * time.Second; got != tc.want {
+				t.Fatalf("ttl = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Códigos de PasswordViolation. O cliente pode traduzir a mensagem a partir
+// do código e de Params.
+const (
+	PasswordTooShort      = "password.too_short"
+	PasswordTooLong       = "password.too_long"
+	PasswordNoUpper       = "password.no_upper"
+	PasswordNoLower       = "password.no_lower"
+	PasswordNoDigit       = "password.no_digit"
+	PasswordNoSymbol      = "password.no_symbol"
+	PasswordBlocked       = "password.blocked"
+	PasswordSimilarToUser = "password.similar_to_user"
+	PasswordReused        = "password.reused"
+)
+
+// PasswordPolicy define as regras para novas senhas. Configure em
+// AppConfig.PasswordPolicy; sem configuração vale DefaultPasswordPolicy.
+type PasswordPolicy struct {
+	MinLength     int // em caracteres
+	MaxLength     int // em bytes (UTF-8), 0 sem limite; limitado ao máximo do hasher
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// BlocklistFile aponta para um arquivo com uma senha proibida por linha
+	// (ex.: senhas comuns ou vazadas). A comparação ignora maiúsculas.
+	BlocklistFile string
+	Blocklist     []string // senhas proibidas adicionais
+	// DisallowUserInfo rejeita senhas que contenham (ou estejam contidas em)
+	// username, e-mail ou nome do usuário.
+	DisallowUserInfo bool
+	// History impede reutilizar a senha atual e as History-1 anteriores.
+	History int
+	// MaxAge obriga a troca de senhas mais antigas que o valor; 0 não expira.
+	MaxAge time.Duration
+
+	once    sync.Once
+	blocked map[string]struct{}
+	loadErr error
+}
+
+// DefaultPasswordPolicy mantém as regras históricas do módulo: ao menos 6
+// caracteres, uma letra maiúscula e um símbolo. O máximo é o do bcrypt.
+var DefaultPasswordPolicy = &PasswordPolicy{
+	MinLength:     6,
+	MaxLength:     BcryptMaxPasswordBytes,
+	RequireUpper:  true,
+	RequireSymbol: true,
+}
+
+// PasswordViolation é uma regra da política não atendida.
+type PasswordViolation struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Params  map[string]any `json:"params,omitempty"`
+}
+
+// PasswordPolicyError reúne todas as violações de uma senha.
+type PasswordPolicyError struct {
+	Message    string              `json:"message"`
+	Violations []PasswordViolation `json:"violations"`
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Message, strings.Join(messages, "; "))
+}
+
+// Load carrega a blocklist. É chamado por ValidateAppConfig para que um
+// arquivo inválido seja detectado na inicialização.
+func (p *PasswordPolicy) Load() error {
+	p.once.Do(func() {
+		p.blocked = make(map[string]struct{})
+		for _, password := range p.Blocklist {
+			p.blocked[strings.ToLower(password)] = struct{}{}
+		}
+		if p.BlocklistFile == "" {
+			return
+		}
+		file, err := os.Open(p.BlocklistFile)
+		if err != nil {
+			p.loadErr = fmt.Errorf("failed to open password blocklist: %s", err.Error())
+			return
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			p.blocked[strings.ToLower(line)] = struct{}{}
+		}
+		if err := scanner.Err(); err != nil {
+			p.loadErr = fmt.Errorf("failed to read password blocklist: %s", err.Error())
+		}
+	})
+	return p.loadErr
+}
+
+// Validate confere a senha e retorna *PasswordPolicyError com todas as
+// violações encontradas. user é opcional e habilita DisallowUserInfo.
+func (p *PasswordPolicy) Validate(password string, user *User) error {
+	return p.validate(password, user, 0)
+}
+
+// validate aplica a política com MaxLength limitado a maxBytes, quando
+// informado.
+func (p *PasswordPolicy) validate(password string, user *User, maxBytes int) error {
+	if err := p.Load(); err != nil {
+		return err
+	}
+	var violations []PasswordViolation
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooShort,
+			Message: fmt.Sprintf("password must have at least %d characters", p.MinLength),
+			Params:  map[string]any{"min": p.MinLength},
+		})
+	}
+	maxLength := p.MaxLength
+	if maxBytes > 0 && (maxLength <= 0 || maxLength > maxBytes) {
+		maxLength = maxBytes
+	}
+	if maxLength > 0 && len(password) > maxLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooLong,
+			Message: fmt.Sprintf("password must have at most %d bytes", maxLength),
+			Params:  map[string]any{"max": maxLength},
+		})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsSymbol(r) || unicode.IsPunct(r): // Símbolos e pontuações
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, PasswordViolation{Code: PasswordNoUpper, Message: "password must contain at least one uppercase letter"})
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, PasswordViolation{Code: PasswordNoLower, Message: "password must contain at least one lowercase letter"})
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, PasswordViolation{Code: PasswordNoDigit, Message: "password must contain at least one digit"})
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, PasswordViolation{Code: PasswordNoSymbol, Message: "password must contain at least one symbol"})
+	}
+
+	if _, ok := p.blocked[strings.ToLower(password)]; ok {
+		violations = append(violations, PasswordViolation{Code: PasswordBlocked, Message: "password is too common"})
+	}
+
+	if p.DisallowUserInfo && user != nil {
+		if field := similarUserField(password, user); field != "" {
+			violations = append(violations, PasswordViolation{
+				Code:    PasswordSimilarToUser,
+				Message: fmt.Sprintf("password is too similar to the %s", field),
+				Params:  map[string]any{"field": field},
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Message: "password does not meet the policy", Violations: violations}
+	}
+	return nil
+}
+
+// similarUserField retorna o campo do usuário contido na senha (ou que a
+// contém), desconsiderando maiúsculas e caracteres não alfanuméricos.
+func similarUserField(password string, user *User) string {
+	normalized := normalizeForSimilarity(password)
+	if normalized == "" {
+		return ""
+	}
+	localPart, _, _ := strings.Cut(user.Email, "@")
+	fields := []struct{ name, value string }{
+		{"username", user.Username},
+		{"email", localPart},
+		{"first name", user.FirstName},
+		{"last name", user.LastName},
+	}
+	for _, field := range fields {
+		value := normalizeForSimilarity(field.value)
+		if len(value) < 3 {
+			continue
+		}
+		if strings.Contains(normalized, value) || strings.Contains(value, normalized) {
+			return field.name
+		}
+	}
+	return ""
+}
+
+func normalizeForSimilarity(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func (a *AppConfig) passwordPolicy() *PasswordPolicy {
+	if a.PasswordPolicy != nil {
+		return a.PasswordPolicy
+	}
+	return DefaultPasswordPolicy
+}
+
+// ValidatePassword aplica a política configurada, respeitando o tamanho
+// máximo aceito pelo hasher. user é opcional.
+func (a *AppConfig) ValidatePassword(password string, user *User) error {
+	maxBytes := 0
+	if limiter, ok := a.hasher().(passwordByteLimiter); ok {
+		maxBytes = limiter.MaxPasswordBytes()
+	}
+	return a.passwordPolicy().validate(password, user, maxBytes)
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestValidatePasswordLength garante que o tamanho máximo é medido em bytes e
+// limitado ao que o hasher aceita, para que toda senha válida possa ser
+// gerada em hash.
+func TestValidatePasswordLength(t *testing.T) {
+	pad := func(n int) string { return "Aa!" + strings.Repeat("x", n-3) }
+	cases := []struct {
+		name     string
+		hasher   PasswordHasher
+		policy   *PasswordPolicy
+		password string
+		tooLong  bool
+	}{
+		{"bcrypt at limit", BcryptHasher{Cost: 4}, nil, pad(72), false},
+		{"bcrypt over limit", BcryptHasher{Cost: 4}, nil, pad(73), true},
+		{"bcrypt multibyte over limit", BcryptHasher{Cost: 4}, nil, "Aa!" + strings.Repeat("€", 24), true},
+		{"bcrypt caps larger policy", BcryptHasher{Cost: 4}, &PasswordPolicy{MaxLength: 128}, pad(100), true},
+		{"bcrypt caps unlimited policy", BcryptHasher{Cost: 4}, &PasswordPolicy{}, pad(100), true},
+		{"argon2id keeps policy", Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}, &PasswordPolicy{MaxLength: 128}, pad(100), false},
+		{"argon2id over policy", Argon2idHasher{Time: 1, Memory: 1024, Threads: 1}, &PasswordPolicy{MaxLength: 128}, pad(129), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &AppConfig{Hasher: tc.hasher, PasswordPolicy: tc.policy}
+			err := config.ValidatePassword(tc.password, nil)
+			var policyErr *PasswordPolicyError
+			tooLong := errors.As(err, &policyErr) && hasViolation(policyErr, PasswordTooLong)
+			if tooLong != tc.tooLong {
+				t.Fatalf("too long = %v, want %v (err %v)", tooLong, tc.tooLong, err)
+			}
+			if !tooLong {
+				if _, err := config.HashPassword(tc.password); err != nil {
+					t.Fatalf("accepted password cannot be hashed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func hasViolation(err *PasswordPolicyError, code string) bool {
+	for _, violation := range err.Violations {
+		if violation.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// TestPasswordPolicyViolations cobre cada regra da política isoladamente.
+func TestPasswordPolicyViolations(t *testing.T) {
+	user := &User{Username: "robert", Email: "bob.smith@example.com", FirstName: "Roberto", LastName: "Smith"}
+	cases := []struct {
+		name     string
+		policy   *PasswordPolicy
+		password string
+		want     string
+	}{
+		{"too short", &PasswordPolicy{MinLength: 8}, "Ab@1", PasswordTooShort},
+		{"short in runes, long in bytes", &PasswordPolicy{MinLength: 5}, "çççç", PasswordTooShort},
+		{"no upper", &PasswordPolicy{RequireUpper: true}, "abc@123", PasswordNoUpper},
+		{"no lower", &PasswordPolicy{RequireLower: true}, "ABC@123", PasswordNoLower},
+		{"no digit", &PasswordPolicy{RequireDigit: true}, "Abc@def", PasswordNoDigit},
+		{"no symbol", &PasswordPolicy{RequireSymbol: true}, "Abc1234", PasswordNoSymbol},
+		{"blocklisted ignoring case", &PasswordPolicy{Blocklist: []string{"Password1!"}}, "PASSWORD1!", PasswordBlocked},
+		{"contains username", &PasswordPolicy{DisallowUserInfo: true}, "Robert@2024", PasswordSimilarToUser},
+		{"contains email local part", &PasswordPolicy{DisallowUserInfo: true}, "x-Bob.Smith-1", PasswordSimilarToUser},
+		{"contained in first name", &PasswordPolicy{DisallowUserInfo: true}, "berto", PasswordSimilarToUser},
+		{"default policy without symbol", DefaultPasswordPolicy, "Abcdef1", PasswordNoSymbol},
+		{"default policy without upper", DefaultPasswordPolicy, "abcdef@1", PasswordNoUpper},
+		{"accepted", &PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true, DisallowUserInfo: true}, "Xyz@12345", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.Validate(tc.password, user)
+			if tc.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) || !hasViolation(policyErr, tc.want) {
+				t.Fatalf("err = %v, want violation %s", err, tc.want)
+			}
+		})
+	}
+}
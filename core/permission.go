@@ -1,14 +1,136 @@
 package core
 
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// PermissionCode identifica uma permissão no formato "recurso:ação". Na
+// concessão, "*" vale para qualquer segmento: "users:*", "*:view" ou "*".
 type PermissionCode string
 
 const (
-	PermissionSuperUser            PermissionCode = "super_user"
-	PermissionCreateUser           PermissionCode = "create_user"
-	PermissionViewUser             PermissionCode = "view_user"
-	PermissionUpdateUser           PermissionCode = "update_user"
-	PermissionEditePermissionsUser PermissionCode = "edite_permissions_user"
-	PermissionCreateRole           PermissionCode = "create_role"
-	PermissionViewRole             PermissionCode = "view_role"
-	PermissionUpdateRole           PermissionCode = "update_role"
+	PermissionSuperUser            PermissionCode = "system:superuser"
+	PermissionCreateUser           PermissionCode = "users:create"
+	PermissionViewUser             PermissionCode = "users:view"
+	PermissionUpdateUser           PermissionCode = "users:update"
+	PermissionEditePermissionsUser PermissionCode = "users:edit_permissions"
+	PermissionCreateRole           PermissionCode = "roles:create"
+	PermissionViewRole             PermissionCode = "roles:view"
+	PermissionUpdateRole           PermissionCode = "roles:update"
+	PermissionCreateTenant         PermissionCode = "tenants:create"
+	PermissionViewTenant           PermissionCode = "tenants:view"
+	PermissionUpdateTenant         PermissionCode = "tenants:update"
+)
+
+// legacyPermissionCodes mapeia os códigos antigos, sem namespace, para os
+// atuais; usado na migração do PreReady.
+var legacyPermissionCodes = map[PermissionCode]PermissionCode{
+	"super_user":             PermissionSuperUser,
+	"create_user":            PermissionCreateUser,
+	"view_user":              PermissionViewUser,
+	"update_user":            PermissionUpdateUser,
+	"edite_permissions_user": PermissionEditePermissionsUser,
+	"create_role":            PermissionCreateRole,
+	"view_role":              PermissionViewRole,
+	"update_role":            PermissionUpdateRole,
+}
+
+const permissionWildcard = "*"
+
+var (
+	implicationsMu sync.RWMutex
+	// implications declara, por ação, as ações implicadas em qualquer recurso.
+	implications = map[string][]string{
+		"update": {"view"},
+	}
 )
+
+// ImplyPermission declara que a ação action implica implied em qualquer
+// recurso (ex.: ImplyPermission("update", "view") faz "users:update"
+// satisfazer "users:view"). As implicações são transitivas.
+func ImplyPermission(action string, implied ...string) {
+	implicationsMu.Lock()
+	defer implicationsMu.Unlock()
+	implications[action] = append(implications[action], implied...)
+}
+
+// HasPermission informa se as permissões concedidas satisfazem required,
+// considerando curingas e implicações.
+func HasPermission(granted []string, required PermissionCode) bool {
+	for _, code := range granted {
+		for _, expanded := range expandPermission(code) {
+			if matchPermission(expanded, string(required)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandPermission retorna o código concedido e os implicados pela sua ação.
+func expandPermission(code string) []string {
+	resource, action, found := strings.Cut(code, ":")
+	if !found {
+		return []string{code}
+	}
+	implicationsMu.RLock()
+	defer implicationsMu.RUnlock()
+
+	codes := []string{code}
+	seen := map[string]bool{action: true}
+	pending := []string{action}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		for _, implied := range implications[current] {
+			if seen[implied] {
+				continue
+			}
+			seen[implied] = true
+			codes = append(codes, resource+":"+implied)
+			pending = append(pending, implied)
+		}
+	}
+	return codes
+}
+
+// matchPermission compara segmento a segmento; "*" concedido casa com
+// qualquer valor, e "*" sozinho casa com qualquer código.
+func matchPermission(granted, required string) bool {
+	if granted == permissionWildcard || granted == required {
+		return true
+	}
+	grantedParts := strings.Split(granted, ":")
+	requiredParts := strings.Split(required, ":")
+	if len(grantedParts) != len(requiredParts) {
+		return false
+	}
+	for i, part := range grantedParts {
+		if part != permissionWildcard && part != requiredParts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// RenamePermissions migra códigos de permissão, preservando os vínculos com
+// roles e chaves de API. Códigos de destino já existentes são mantidos.
+func (a *AppConfig) RenamePermissions(codes map[PermissionCode]PermissionCode) error {
+	for from, to := range codes {
+		var count int64
+		if err := a.GormStore.Model(&Permission{}).Where("code = ?", string(to)).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to query database: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+		if err := a.GormStore.Model(&Permission{}).
+			Where("code = ?", string(from)).
+			Updates(map[string]any{"code": string(to), "name": string(to)}).Error; err != nil {
+			return fmt.Errorf("failed to rename permission '%s': %s", from, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestHasPermission(t *testing.T) {
+	// Cadeia de teste: publish implica update, que já implica view
+	ImplyPermission("test_publish", "update")
+
+	cases := []struct {
+		name     string
+		granted  []string
+		required PermissionCode
+		want     bool
+	}{
+		{"exact", []string{"users:view"}, "users:view", true},
+		{"other resource", []string{"users:view"}, "roles:view", false},
+		{"resource wildcard", []string{"users:*"}, "users:edit_permissions", true},
+		{"resource wildcard other resource", []string{"users:*"}, "roles:view", false},
+		{"global wildcard", []string{"*"}, "tenants:update", true},
+		{"action wildcard", []string{"*:view"}, "roles:view", true},
+		{"action wildcard other action", []string{"*:view"}, "roles:update", false},
+		{"nested namespace", []string{"billing:invoices:*"}, "billing:invoices:view", true},
+		{"nested namespace depth differs", []string{"billing:invoices:*"}, "billing:view", false},
+		{"update implies view", []string{"users:update"}, "users:view", true},
+		{"view does not imply update", []string{"users:view"}, "users:update", false},
+		{"implication keeps resource", []string{"users:update"}, "roles:view", false},
+		{"implication chain first step", []string{"docs:test_publish"}, "docs:update", true},
+		{"implication chain transitive", []string{"docs:test_publish"}, "docs:view", true},
+		{"legacy code", []string{"create_user"}, "create_user", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := HasPermission(tc.granted, tc.required); got != tc.want {
+				t.Fatalf("HasPermission(%v, %s) = %v, want %v", tc.granted, tc.required, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestDenyBeatsImpliedGrant garante que a negação direta vale também contra
+// permissões obtidas por curinga ou implicação.
+func TestDenyBeatsImpliedGrant(t *testing.T) {
+	cases := []struct {
+		name     string
+		claims   *JwtClaims
+		required PermissionCode
+		want     bool
+	}{
+		{"implied view denied", &JwtClaims{Permissions: []string{"users:update"}, Denies: []string{"users:view"}}, "users:view", false},
+		{"implying grant kept", &JwtClaims{Permissions: []string{"users:update"}, Denies: []string{"users:view"}}, "users:update", true},
+		{"wildcard grant denied", &JwtClaims{Permissions: []string{"users:*"}, Denies: []string{"users:update"}}, "users:update", false},
+		{"wildcard deny", &JwtClaims{Permissions: []string{"users:update"}, Denies: []string{"users:*"}}, "users:view", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.required.SatisfiedBy(tc.claims); got != tc.want {
+				t.Fatalf("SatisfiedBy = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestRenamePermissions garante que a migração de códigos mantém os vínculos
+// com as roles e não sobrescreve um código de destino existente.
+func TestRenamePermissions(t *testing.T) {
+	a := newTestApp(t)
+	legacy := a.permission("report_view")
+	role := a.createRole("reporter", "report_view")
+	kept := a.permission("report_edit")
+	a.permission("reports:update")
+
+	if err := a.r.RenamePermissions(map[PermissionCode]PermissionCode{
+		"report_view": "reports:view",
+		"report_edit": "reports:update",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		id   uint
+		want string
+	}{
+		{"renamed", legacy.ID, "reports:view"},
+		{"destination exists", kept.ID, "report_edit"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var permission Permission
+			a.r.GormStore.First(&permission, tc.id)
+			if permission.Code != tc.want {
+				t.Fatalf("code = %s, want %s", permission.Code, tc.want)
+			}
+		})
+	}
+
+	var reloaded Role
+	a.r.GormStore.Preload("Permissions").First(&reloaded, role.ID)
+	if len(reloaded.Permissions) != 1 || reloaded.Permissions[0].Code != "reports:view" {
+		t.Fatalf("role lost the renamed permission: %+v", reloaded.Permissions)
+	}
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultPermissionCacheTTL é o intervalo entre consultas ao contador de
+// versão no banco. Alterações feitas nesta instância valem imediatamente; as
+// feitas por outras instâncias passam a valer em até este intervalo.
+const DefaultPermissionCacheTTL = 5 * time.Second
+
+// ErrUserInactive indica que o usuário do token foi desativado ou removido.
+var ErrUserInactive = errors.New("user is inactive")
+
+// PermissionResolver resolve as permissões atuais do usuário a partir do
+// banco, com cache por usuário e tenant invalidado por um contador de versão global
+// incrementado em qualquer alteração de roles, permissões ou usuários.
+type PermissionResolver struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu        sync.RWMutex
+	version   int64
+	checkedAt time.Time
+	users     map[permissionKey]*ResolvedPermissions
+}
+
+type permissionKey struct {
+	user   uint
+	tenant uint
+}
+
+// ResolvedPermissions é o estado atual de autorização de um usuário.
+type ResolvedPermissions struct {
+	Active      bool
+	IsSuperUser bool
+	Permissions []string
+	Denies      []string
+	version     int64
+}
+
+func NewPermissionResolver(db *gorm.DB, ttl time.Duration) *PermissionResolver {
+	if ttl <= 0 {
+		ttl = DefaultPermissionCacheTTL
+	}
+	return &PermissionResolver{
+		db:    db,
+		ttl:   ttl,
+		users: make(map[permissionKey]*ResolvedPermissions),
+	}
+}
+
+// Resolve retorna as permissões atuais do usuário no tenant (zero para o
+// escopo global). Usuários removidos ou sem vínculo ativo com o tenant são
+// tratados como inativos.
+func (r *PermissionResolver) Resolve(userID uint, tenantID uint) (*ResolvedPermissions, error) {
+	version, err := r.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	key := permissionKey{user: userID, tenant: tenantID}
+	entry, ok := r.users[key]
+	r.mu.RUnlock()
+	if ok && entry.version == version {
+		return entry, nil
+	}
+
+	var user User
+	err = r.db.Preload("Roles.Permissions").Preload("PermissionOverrides.Permission").Where("id = ?", userID).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+	entry = &ResolvedPermissions{version: version}
+	if err == nil {
+		err = loadRoleParents(r.db, user.Roles)
+	}
+	if err == nil {
+		scoped, err := withTenantRoles(r.db, &user, tenantID)
+		if err != nil && !errors.Is(err, ErrNotTenantMember) {
+			return nil, err
+		}
+		if err == nil {
+			entry.Active = user.Active
+			entry.IsSuperUser = user.IsSuperUser
+			entry.Permissions = ExtractCodePermissionsByUser(scoped)
+			entry.Denies = ExtractDeniedCodesByUser(scoped)
+		}
+	}
+
+	r.mu.Lock()
+	if len(r.users) >= revocationCacheSweep {
+		r.users = make(map[permissionKey]*ResolvedPermissions)
+	}
+	r.users[key] = entry
+	r.mu.Unlock()
+	return entry, nil
+}
+
+// Bump incrementa o contador de versão, invalidando o cache de todas as
+// instâncias. A linha do contador é criada se ainda não existir.
+func (r *PermissionResolver) Bump() error {
+	for bumped := false; !bumped; {
+		result := r.db.Model(&PermissionVersion{}).
+			Where("id = ?", permissionVersionID).
+			Update("version", gorm.Expr("version + 1"))
+		if result.Error != nil {
+			return fmt.Errorf("failed to bump permissions version: %s", result.Error.Error())
+		}
+		if result.RowsAffected > 0 {
+			break
+		}
+		// Sem a linha, ela é recriada com uma nova versão; se outra instância a
+		// criou antes, o incremento é refeito
+		created := r.db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&PermissionVersion{ID: permissionVersionID, Version: newPermissionVersion()})
+		if created.Error != nil {
+			return fmt.Errorf("failed to bump permissions version: %s", created.Error.Error())
+		}
+		bumped = created.RowsAffected > 0
+	}
+	r.mu.Lock()
+	r.checkedAt = time.Time{}
+	r.users = make(map[permissionKey]*ResolvedPermissions)
+	r.mu.Unlock()
+	return nil
+}
+
+// Apply substitui permissões e superusuário dos claims pelos valores atuais.
+// Retorna ErrUserInactive se o usuário foi desativado ou perdeu o vínculo com
+// o tenant do token.
+func (r *PermissionResolver) Apply(claims *JwtClaims) (*JwtClaims, error) {
+	resolved, err := r.Resolve(claims.Sub, claims.Tid)
+	if err != nil {
+		return nil, err
+	}
+	if !resolved.Active {
+		return nil, ErrUserInactive
+	}
+	live := *claims
+	live.Permissions = resolved.Permissions
+	live.Denies = resolved.Denies
+	live.IsSuperUser = resolved.IsSuperUser
+	return &live, nil
+}
+
+func (r *PermissionResolver) currentVersion() (int64, error) {
+	r.mu.RLock()
+	version, checkedAt := r.version, r.checkedAt
+	r.mu.RUnlock()
+	if !checkedAt.IsZero() && time.Since(checkedAt) < r.ttl {
+		return version, nil
+	}
+
+	record := PermissionVersion{ID: permissionVersionID}
+	if err := r.db.Attrs(PermissionVersion{Version: newPermissionVersion()}).FirstOrCreate(&record).Error; err != nil {
+		return 0, fmt.Errorf("failed to query permissions version: %s", err.Error())
+	}
+	r.mu.Lock()
+	r.version = record.Version
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return record.Version, nil
+}
+
+// newPermissionVersion é a versão inicial de uma linha de PermissionVersion
+// criada (ou recriada, como após truncar a tabela): baseada no relógio, para
+// não repetir uma versão já guardada em cache pelas instâncias.
+func newPermissionVersion() int64 {
+	return time.Now().UnixNano()
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestPermissionResolverBump garante que o incremento invalida o cache das
+// demais instâncias mesmo sem a linha do contador no banco.
+func TestPermissionResolverBump(t *testing.T) {
+	cases := []struct {
+		name       string
+		missingRow bool
+	}{
+		{"existing row", false},
+		{"missing row", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t)
+			db := a.r.GormStore
+			db.Save(&PermissionVersion{ID: permissionVersionID, Version: 1})
+			role := a.createRole("viewer", PermissionViewUser)
+			bob := a.createUser("bob", role)
+
+			local := NewPermissionResolver(db, time.Hour)
+			other := NewPermissionResolver(db, time.Nanosecond)
+			before, err := other.Resolve(bob.ID, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(before.Permissions); got != "[users:view]" {
+				t.Fatalf("permissions = %s", got)
+			}
+
+			// Ex.: tabela truncada depois que as instâncias já leram a versão
+			if tc.missingRow {
+				db.Delete(&PermissionVersion{}, permissionVersionID)
+			}
+			db.Model(&role).Association("Permissions").Append([]Permission{a.permission(PermissionViewRole)})
+			if err := local.Bump(); err != nil {
+				t.Fatal(err)
+			}
+			var record PermissionVersion
+			db.First(&record, permissionVersionID)
+			if record.Version == 1 || (!tc.missingRow && record.Version != 2) {
+				t.Fatalf("version = %d after bump", record.Version)
+			}
+			after, err := other.Resolve(bob.ID, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprint(after.Permissions); got != "[users:view roles:view]" {
+				t.Fatalf("stale permissions on other instance: %s", got)
+			}
+		})
+	}
+}
+
+// TestLivePermissions garante que, com AppAuth.LivePermissions, o mesmo token
+// reflete na hora a retirada de permissões e a desativação do usuário.
+func TestLivePermissions(t *testing.T) {
+	a := newTestApp(t, func(c *AppConfig) { c.Auth.LivePermissions = true })
+	role := a.createRole("viewer", PermissionViewTenant)
+	bob := a.createUser("bob", role)
+	a.createTenant("Acme", bob)
+	bobToken, _ := a.login("bob", testPassword)
+	admin, _ := a.login(testSuperUser, testSuperPass)
+
+	steps := []struct {
+		name   string
+		change func()
+		path   string
+		status int
+	}{
+		{"permission from role", nil, "/tenants/?page=1&limit=10", fiber.StatusOK},
+		{"permission removed from role", func() {
+			a.r.GormStore.Model(&role).Association("Permissions").Clear()
+			if err := a.r.Permissions.Bump(); err != nil {
+				t.Fatal(err)
+			}
+		}, "/tenants/?page=1&limit=10", fiber.StatusUnauthorized},
+		{"active user", nil, "/auth/me", fiber.StatusOK},
+		{"user deactivated", func() {
+			status, _, raw := a.do("PUT", fmt.Sprintf("/users/%d", bob.ID), admin, map[string]any{
+				"firstName": "bob",
+				"username":  "bob",
+				"email":     "bob@example.com",
+				"phone1":    "+5511911112222",
+				"active":    false,
+			})
+			if status != fiber.StatusOK {
+				t.Fatalf("deactivate: %d %s", status, raw)
+			}
+		}, "/auth/me", fiber.StatusUnauthorized},
+	}
+	for _, step := range steps {
+		if step.change != nil {
+			step.change()
+		}
+		if status, _, raw := a.do("GET", step.path, bobToken, nil); status != step.status {
+			t.Fatalf("%s: status %d, want %d: %s", step.name, status, step.status, raw)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Tipos de recurso e ações registrados pelo módulo.
+const (
+	ResourceUser = "user"
+
+	// PolicyUpdate permite editar os dados básicos e gerenciar sessões e tokens.
+	PolicyUpdate = "update"
+	// PolicyAdminister permite alterar roles, identificação e superusuário.
+	PolicyAdminister = "administer"
+)
+
+// Resource descreve o alvo de uma ação, com os atributos usados pelas
+// políticas.
+type Resource struct {
+	Type   string
+	ID     uint
+	Owner  uint // usuário dono do recurso
+	Tenant uint
+	Attrs  map[string]any
+}
+
+// PolicyDecision é o resultado de uma política.
+type PolicyDecision int
+
+const (
+	PolicyAbstain PolicyDecision = iota // a política não se aplica
+	PolicyAllow
+	PolicyDeny // prevalece sobre qualquer Allow, inclusive de superusuários
+)
+
+// Policy decide se subject pode executar a ação sobre resource.
+type Policy func(subject *JwtClaims, resource *Resource) PolicyDecision
+
+// PolicyEngine avalia ações sobre recursos.
+type PolicyEngine interface {
+	Can(subject *JwtClaims, action string, resource *Resource) error
+}
+
+// Policies é o PolicyEngine padrão: políticas registradas por tipo de recurso
+// e ação. Qualquer Deny nega; sem Allow, a ação é negada. Superusuários são
+// permitidos salvo Deny explícito.
+type Policies struct {
+	mu    sync.RWMutex
+	rules map[string][]Policy
+}
+
+func NewPolicies() *Policies {
+	policies := &Policies{rules: make(map[string][]Policy)}
+	policies.Register(ResourceUser, PolicyUpdate, AllowOwner)
+	return policies
+}
+
+// Register adiciona uma política para a ação sobre o tipo de recurso.
+func (p *Policies) Register(resourceType string, action string, policy Policy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := resourceType + ":" + action
+	p.rules[key] = append(p.rules[key], policy)
+}
+
+func (p *Policies) Can(subject *JwtClaims, action string, resource *Resource) error {
+	if subject == nil {
+		return fmt.Errorf("missing jwt claims")
+	}
+	p.mu.RLock()
+	rules := p.rules[resource.Type+":"+action]
+	p.mu.RUnlock()
+
+	allowed := subject.IsSuperUser
+	for _, rule := range rules {
+		switch rule(subject, resource) {
+		case PolicyDeny:
+			return policyDenied(action, resource)
+		case PolicyAllow:
+			allowed = true
+		}
+	}
+	if !allowed {
+		return policyDenied(action, resource)
+	}
+	return nil
+}
+
+func policyDenied(action string, resource *Resource) error {
+	return fmt.Errorf("permission denied: cannot %s %s '%v'", action, resource.Type, resource.ID)
+}
+
+// AllowOwner permite a ação ao dono do recurso.
+func AllowOwner(subject *JwtClaims, resource *Resource) PolicyDecision {
+	if resource.Owner != 0 && subject.Sub == resource.Owner {
+		return PolicyAllow
+	}
+	return PolicyAbstain
+}
+
+// AllowPermission permite a ação a quem tem a permissão.
+func AllowPermission(code PermissionCode) Policy {
+	return func(subject *JwtClaims, resource *Resource) PolicyDecision {
+		if code.SatisfiedBy(subject) {
+			return PolicyAllow
+		}
+		return PolicyAbstain
+	}
+}
+
+// ResourceLoader monta o recurso da requisição para RequirePolicy.
+type ResourceLoader func(ctx *fiber.Ctx) (*Resource, error)
+
+// Can avalia a ação com o PolicyEngine configurado, para uso nos serviços.
+func (a *AppConfig) Can(subject *JwtClaims, action string, resource *Resource) error {
+	if a.Policies == nil {
+		return fmt.Errorf("policy engine is not configured")
+	}
+	return a.Policies.Can(subject, action, resource)
+}
+
+// RequirePolicy exige que a ação seja permitida sobre o recurso carregado por
+// load; deve vir depois de JWTProtected.
+func (a *AppConfig) RequirePolicy(action string, load ResourceLoader) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, err := GetClaims(ctx)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		resource, err := load(ctx)
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		if err := a.Can(claims, action, resource); err != nil {
+			log.Printf("policy denied user_id=%d %s %s action=%s resource=%s:%d", claims.Sub, ctx.Method(), ctx.Path(), action, resource.Type, resource.ID)
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
+		return ctx.Next()
+	}
+}
+
+// UserResource carrega o usuário do parâmetro :id como recurso.
+func (a *AppConfig) UserResource(ctx *fiber.Ctx) (*Resource, error) {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return nil, fmt.Errorf("invalid user id")
+	}
+	var user User
+	if err := a.GormStore.Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user with id '%v' does not exist", id)
+	}
+	return userResource(&user), nil
+}
+
+func userResource(user *User) *Resource {
+	return &Resource{
+		Type:  ResourceUser,
+		ID:    user.ID,
+		Owner: user.ID,
+		Attrs: map[string]any{
+			"active":      user.Active,
+			"isSuperUser": user.IsSuperUser,
+		},
+	}
+}
+
+// subjectClaims monta os claims de autorização a partir do usuário carregado
+// do banco (permissões atuais no tenant do serviço, não as do token).
+func (s *Service) subjectClaims(user *User) (*JwtClaims, error) {
+	scoped, err := withTenantRoles(s.GormStore, user, s.tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return &JwtClaims{
+		Sub:         user.ID,
+		Tid:         s.tenantID,
+		Permissions: ExtractCodePermissionsByUser(scoped),
+		Denies:      ExtractDeniedCodesByUser(scoped),
+		IsSuperUser: user.IsSuperUser,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestPoliciesCan(t *testing.T) {
+	policies := NewPolicies()
+	policies.Register(ResourceUser, PolicyAdminister, AllowPermission(PermissionUpdateUser))
+	policies.Register(ResourceUser, PolicyAdminister, func(subject *JwtClaims, resource *Resource) PolicyDecision {
+		if resource.Attrs["isSuperUser"] == true {
+			return PolicyDeny
+		}
+		return PolicyAbstain
+	})
+	regular := &Resource{Type: ResourceUser, ID: 2, Owner: 2, Attrs: map[string]any{}}
+	super := &Resource{Type: ResourceUser, ID: 1, Owner: 1, Attrs: map[string]any{"isSuperUser": true}}
+
+	tests := []struct {
+		name     string
+		subject  *JwtClaims
+		action   string
+		resource *Resource
+		allowed  bool
+	}{
+		{"owner updates self", &JwtClaims{Sub: 2}, PolicyUpdate, regular, true},
+		{"other user cannot update", &JwtClaims{Sub: 3}, PolicyUpdate, regular, false},
+		{"superuser updates anyone", &JwtClaims{Sub: 1, IsSuperUser: true}, PolicyUpdate, regular, true},
+		{"permission allows administer", &JwtClaims{Sub: 3, Permissions: []string{"users:update"}}, PolicyAdminister, regular, true},
+		{"wildcard allows administer", &JwtClaims{Sub: 3, Permissions: []string{"users:*"}}, PolicyAdminister, regular, true},
+		{"direct deny beats wildcard", &JwtClaims{Sub: 3, Permissions: []string{"users:*"}, Denies: []string{"users:update"}}, PolicyAdminister, regular, false},
+		{"owner without permission cannot administer", &JwtClaims{Sub: 2}, PolicyAdminister, regular, false},
+		{"deny policy beats superuser", &JwtClaims{Sub: 1, IsSuperUser: true}, PolicyAdminister, super, false},
+		{"unknown action is denied", &JwtClaims{Sub: 2}, "delete", regular, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policies.Can(tt.subject, tt.action, tt.resource)
+			if (err == nil) != tt.allowed {
+				t.Fatalf("allowed = %v, want %v (%v)", err == nil, tt.allowed, err)
+			}
+		})
+	}
+}
+
+// TestSubjectClaimsCarryDenies garante que as verificações de política no
+// serviço respeitam as negações diretas do usuário.
+func TestSubjectClaimsCarryDenies(t *testing.T) {
+	a := newTestApp(t)
+	a.r.Policies.(*Policies).Register(ResourceUser, PolicyUpdate, AllowPermission(PermissionUpdateUser))
+	editor := a.createUser("editor", a.createRole("user-admin", "users:*"))
+	target := a.createUser("target")
+	service := a.r.Controller.Service
+
+	if err := service.canManageUser(editor.ID, target.ID); err != nil {
+		t.Fatalf("wildcard grant: %v", err)
+	}
+	if err := a.r.GormStore.Create(&UserPermission{
+		UserID:       editor.ID,
+		PermissionID: a.permission(PermissionUpdateUser).ID,
+		Effect:       PermissionDeny,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := service.canManageUser(editor.ID, target.ID); err == nil {
+		t.Fatal("direct deny ignored by service-side policy check")
+	}
+}
@@ -6,9 +6,36 @@ func (config *AppConfig) PreReady() error {
 		&User{},
 		&Role{},
 		&Permission{},
+		&Session{},
+		&RefreshToken{},
+		&RevokedToken{},
+		&UserRevocation{},
+		&MfaRecoveryCode{},
+		&PasswordReset{},
+		&ApiKey{},
+		&UserIdentity{},
+		&OidcState{},
+		&LoginThrottle{},
+		&IdentifierThrottle{},
+		&PasswordHistory{},
+		&AuditLog{},
+		&PermissionVersion{},
+		&Tenant{},
+		&Membership{},
+		&UserPermission{},
 	); err != nil {
 		return err
 	}
+	// Nomes de roles passaram a ser únicos por tenant
+	if config.GormStore.Migrator().HasIndex(&Role{}, "idx_roles_name") {
+		if err := config.GormStore.Migrator().DropIndex(&Role{}, "idx_roles_name"); err != nil {
+			return err
+		}
+	}
+	// Migra os códigos de permissão sem namespace
+	if err := config.RenamePermissions(legacyPermissionCodes); err != nil {
+		return err
+	}
 	// Exe. Seeds
 	if config.Super != nil {
 		if err := config.SaveUserAdmin(); err != nil {
@@ -25,6 +52,9 @@ func (config *AppConfig) PreReady() error {
 		PermissionCreateRole,
 		PermissionViewRole,
 		PermissionUpdateRole,
+		PermissionCreateTenant,
+		PermissionViewTenant,
+		PermissionUpdateTenant,
 	); err != nil {
 		return err
 	}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Requirement é uma expressão sobre as permissões de um token. PermissionCode
+// é o caso básico; AllOf, AnyOf e Not combinam requisitos.
+type Requirement interface {
+	SatisfiedBy(claims *JwtClaims) bool
+	String() string
+}
+
+// SatisfiedBy exige a permissão concedida e não negada diretamente.
+func (p PermissionCode) SatisfiedBy(claims *JwtClaims) bool {
+	return HasPermission(claims.Permissions, p) && !IsDenied(claims.Denies, p)
+}
+
+func (p PermissionCode) String() string {
+	return string(p)
+}
+
+type allOf []Requirement
+
+// AllOf exige todos os requisitos. Sem requisitos, é sempre satisfeito.
+func AllOf(requirements ...Requirement) Requirement {
+	return allOf(requirements)
+}
+
+func (r allOf) SatisfiedBy(claims *JwtClaims) bool {
+	for _, requirement := range r {
+		if !requirement.SatisfiedBy(claims) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r allOf) String() string {
+	return joinRequirements("allOf", r)
+}
+
+type anyOf []Requirement
+
+// AnyOf exige ao menos um dos requisitos. Sem requisitos, nunca é satisfeito.
+func AnyOf(requirements ...Requirement) Requirement {
+	return anyOf(requirements)
+}
+
+func (r anyOf) SatisfiedBy(claims *JwtClaims) bool {
+	for _, requirement := range r {
+		if requirement.SatisfiedBy(claims) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r anyOf) String() string {
+	return joinRequirements("anyOf", r)
+}
+
+type not struct {
+	requirement Requirement
+}
+
+// Not nega o requisito.
+func Not(requirement Requirement) Requirement {
+	return not{requirement}
+}
+
+func (r not) SatisfiedBy(claims *JwtClaims) bool {
+	return !r.requirement.SatisfiedBy(claims)
+}
+
+func (r not) String() string {
+	return fmt.Sprintf("not(%s)", r.requirement)
+}
+
+func joinRequirements(name string, requirements []Requirement) string {
+	parts := make([]string, 0, len(requirements))
+	for _, requirement := range requirements {
+		parts = append(parts, requirement.String())
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(parts, ", "))
+}
+
+// Authorize confere o requisito contra os claims, para uso dentro dos
+// serviços. Superusuários satisfazem qualquer requisito.
+func Authorize(claims *JwtClaims, requirement Requirement) error {
+	if claims == nil {
+		return fmt.Errorf("missing jwt claims")
+	}
+	if claims.IsSuperUser || requirement.SatisfiedBy(claims) {
+		return nil
+	}
+	return fmt.Errorf("permission denied: requires %s", requirement)
+}
+
+// Require exige o requisito dos claims validados; deve vir depois de
+// JWTProtected. Negações são logadas com o requisito não atendido.
+func Require(requirement Requirement) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		claims, err := GetClaims(ctx)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		if err := Authorize(claims, requirement); err != nil {
+			logDenied(ctx, claims, requirement)
+			return fiber.NewError(fiber.StatusForbidden, err.Error())
+		}
+		return ctx.Next()
+	}
+}
+
+func logDenied(ctx *fiber.Ctx, claims *JwtClaims, requirement Requirement) {
+	log.Printf("permission denied user_id=%d %s %s requirement=%s", claims.Sub, ctx.Method(), ctx.Path(), requirement)
+}
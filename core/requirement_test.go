@@ -0,0 +1,86 @@
+package core
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequirementSatisfiedBy(t *testing.T) {
+	viewer := &JwtClaims{Permissions: []string{"users:view", "roles:view"}}
+	denied := &JwtClaims{Permissions: []string{"users:view", "roles:view"}, Denies: []string{"users:view"}}
+
+	cases := []struct {
+		name        string
+		claims      *JwtClaims
+		requirement Requirement
+		want        bool
+	}{
+		{"permission held", viewer, PermissionViewUser, true},
+		{"permission missing", viewer, PermissionUpdateUser, false},
+		{"allOf all held", viewer, AllOf(PermissionViewUser, PermissionViewRole), true},
+		{"allOf one missing", viewer, AllOf(PermissionViewUser, PermissionUpdateUser), false},
+		{"allOf empty", viewer, AllOf(), true},
+		{"anyOf one held", viewer, AnyOf(PermissionUpdateUser, PermissionViewRole), true},
+		{"anyOf none held", viewer, AnyOf(PermissionUpdateUser, PermissionUpdateRole), false},
+		{"anyOf empty", viewer, AnyOf(), false},
+		{"not of missing", viewer, Not(PermissionUpdateUser), true},
+		{"not of held", viewer, Not(PermissionViewUser), false},
+		{"deny beats nested anyOf", denied, AllOf(PermissionViewRole, AnyOf(PermissionViewUser, PermissionUpdateUser)), false},
+		{"deny leaves other anyOf branch", denied, AnyOf(PermissionViewUser, PermissionViewRole), true},
+		{"not of denied", denied, Not(PermissionViewUser), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.requirement.SatisfiedBy(tc.claims); got != tc.want {
+				t.Fatalf("%s = %v, want %v", tc.requirement, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequirementString(t *testing.T) {
+	requirement := AllOf(PermissionViewUser, AnyOf(PermissionViewRole, Not(PermissionUpdateUser)))
+	if got, want := requirement.String(), "allOf(users:view, anyOf(roles:view, not(users:update)))"; got != want {
+		t.Fatalf("String() = %s, want %s", got, want)
+	}
+}
+
+// TestRequire cobre o middleware: 403 para requisito não atendido e 401 sem
+// claims validados.
+func TestRequire(t *testing.T) {
+	requirement := AllOf(PermissionViewUser, Not(PermissionUpdateUser))
+	cases := []struct {
+		name   string
+		claims *JwtClaims
+		status int
+	}{
+		{"satisfied", &JwtClaims{Permissions: []string{"users:view"}}, fiber.StatusOK},
+		{"missing permission", &JwtClaims{Permissions: []string{"roles:view"}}, fiber.StatusForbidden},
+		{"excluded by not", &JwtClaims{Permissions: []string{"users:view", "users:update"}}, fiber.StatusForbidden},
+		{"denied directly", &JwtClaims{Permissions: []string{"users:view"}, Denies: []string{"users:view"}}, fiber.StatusForbidden},
+		{"superuser", &JwtClaims{IsSuperUser: true}, fiber.StatusOK},
+		{"without claims", nil, fiber.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/", func(ctx *fiber.Ctx) error {
+				if tc.claims != nil {
+					ctx.Locals("claims", tc.claims)
+				}
+				return ctx.Next()
+			}, Require(requirement), func(ctx *fiber.Ctx) error {
+				return ctx.SendStatus(fiber.StatusOK)
+			})
+			res, err := app.Test(httptest.NewRequest("GET", "/", nil), -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tc.status {
+				t.Fatalf("status %d, want %d", res.StatusCode, tc.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultRevocationCacheTTL é o tempo que uma consulta negativa fica em cache.
+// Revogações feitas nesta instância invalidam o cache imediatamente; as feitas
+// por outras instâncias passam a valer em até este intervalo.
+const DefaultRevocationCacheTTL = 30 * time.Second
+
+const revocationCacheSweep = 10000
+
+// RevocationStore mantém a lista de tokens revogados, por jti, por sessão ou
+// por um corte "emitidos antes de" por usuário, com cache em memória na frente
+// do banco.
+type RevocationStore struct {
+	db  *gorm.DB
+	ttl time.Duration
+
+	mu       sync.RWMutex
+	jtis     map[string]revocationEntry
+	users    map[uint]revocationEntry
+	sessions map[uint]revocationEntry
+}
+
+type revocationEntry struct {
+	revoked  bool
+	cutoff   time.Time
+	cachedAt time.Time
+}
+
+func NewRevocationStore(db *gorm.DB, ttl time.Duration) *RevocationStore {
+	if ttl <= 0 {
+		ttl = DefaultRevocationCacheTTL
+	}
+	return &RevocationStore{
+		db:       db,
+		ttl:      ttl,
+		jtis:     make(map[string]revocationEntry),
+		users:    make(map[uint]revocationEntry),
+		sessions: make(map[uint]revocationEntry),
+	}
+}
+
+// RevokeToken revoga um único token até a sua expiração.
+func (r *RevocationStore) RevokeToken(jti string, userID uint, expiresAt time.Time) error {
+	if jti == "" {
+		return fmt.Errorf("token without jti cannot be revoked")
+	}
+	record := RevokedToken{Jti: jti, UserID: userID, ExpiresAt: expiresAt}
+	if err := r.db.
+		Where(RevokedToken{Jti: jti}).
+		FirstOrCreate(&record).Error; err != nil {
+		return fmt.Errorf("failed to revoke token: %s", err.Error())
+	}
+
+	r.mu.Lock()
+	r.jtis[jti] = revocationEntry{revoked: true, cachedAt: time.Now()}
+	r.mu.Unlock()
+	return nil
+}
+
+// RevokeUser revoga todos os tokens do usuário emitidos até before.
+func (r *RevocationStore) RevokeUser(userID uint, before time.Time) error {
+	record := UserRevocation{UserID: userID}
+	if err := r.db.
+		Where(UserRevocation{UserID: userID}).
+		Assign(UserRevocation{RevokedBefore: before}).
+		FirstOrCreate(&record).Error; err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %s", err.Error())
+	}
+
+	r.mu.Lock()
+	r.users[userID] = revocationEntry{cutoff: before, cachedAt: time.Now()}
+	r.mu.Unlock()
+	return nil
+}
+
+// RevokeSession invalida todos os tokens emitidos para a sessão.
+func (r *RevocationStore) RevokeSession(sessionID uint, at time.Time) error {
+	if err := r.db.Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", at).Error; err != nil {
+		return fmt.Errorf("failed to revoke session: %s", err.Error())
+	}
+
+	r.mu.Lock()
+	r.sessions[sessionID] = revocationEntry{revoked: true, cachedAt: time.Now()}
+	r.mu.Unlock()
+	return nil
+}
+
+// IsRevoked informa se os claims pertencem a um token revogado.
+func (r *RevocationStore) IsRevoked(claims *JwtClaims) (bool, error) {
+	if claims.ID != "" {
+		revoked, err := r.isTokenRevoked(claims.ID)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	if claims.Sid != 0 {
+		revoked, err := r.isSessionRevoked(claims.Sid)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+
+	cutoff, err := r.userCutoff(claims.Sub)
+	if err != nil {
+		return false, err
+	}
+	if cutoff.IsZero() {
+		return false, nil
+	}
+	if claims.IssuedAt == nil {
+		return true, nil
+	}
+	// O iat só tem segundos inteiros. Sem sessão, o segundo do corte também é
+	// revogado; com sessão, ele é coberto pela revogação das sessões, e um
+	// novo login no mesmo segundo continua válido.
+	if claims.Sid != 0 {
+		return claims.IssuedAt.Unix() < cutoff.Unix(), nil
+	}
+	return claims.IssuedAt.Unix() <= cutoff.Unix(), nil
+}
+
+// Purge remove do banco as revogações de tokens que já expiraram.
+func (r *RevocationStore) Purge() error {
+	if err := r.db.
+		Where("expires_at < ?", time.Now()).
+		Delete(&RevokedToken{}).Error; err != nil {
+		return fmt.Errorf("failed to purge revoked tokens: %s", err.Error())
+	}
+	return nil
+}
+
+func (r *RevocationStore) isTokenRevoked(jti string) (bool, error) {
+	now := time.Now()
+	r.mu.RLock()
+	entry, ok := r.jtis[jti]
+	r.mu.RUnlock()
+	if ok && (entry.revoked || now.Sub(entry.cachedAt) < r.ttl) {
+		return entry.revoked, nil
+	}
+
+	var record RevokedToken
+	err := r.db.Where("jti = ?", jti).First(&record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, fmt.Errorf("failed to query revoked tokens: %w", err)
+	}
+	revoked := err == nil
+
+	r.mu.Lock()
+	if len(r.jtis) >= revocationCacheSweep {
+		sweep(r.jtis, now, r.ttl)
+	}
+	r.jtis[jti] = revocationEntry{revoked: revoked, cachedAt: now}
+	r.mu.Unlock()
+	return revoked, nil
+}
+
+func (r *RevocationStore) isSessionRevoked(sessionID uint) (bool, error) {
+	now := time.Now()
+	r.mu.RLock()
+	entry, ok := r.sessions[sessionID]
+	r.mu.RUnlock()
+	if ok && (entry.revoked || now.Sub(entry.cachedAt) < r.ttl) {
+		return entry.revoked, nil
+	}
+
+	var session Session
+	if err := r.db.Select("id", "revoked_at").Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return true, nil
+		}
+		return false, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	revoked := session.RevokedAt != nil
+
+	r.mu.Lock()
+	if len(r.sessions) >= revocationCacheSweep {
+		sweep(r.sessions, now, r.ttl)
+	}
+	r.sessions[sessionID] = revocationEntry{revoked: revoked, cachedAt: now}
+	r.mu.Unlock()
+	return revoked, nil
+}
+
+func (r *RevocationStore) userCutoff(userID uint) (time.Time, error) {
+	now := time.Now()
+	r.mu.RLock()
+	entry, ok := r.users[userID]
+	r.mu.RUnlock()
+	if ok && now.Sub(entry.cachedAt) < r.ttl {
+		return entry.cutoff, nil
+	}
+
+	var record UserRevocation
+	err := r.db.Where("user_id = ?", userID).First(&record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, fmt.Errorf("failed to query user revocations: %w", err)
+	}
+
+	r.mu.Lock()
+	r.users[userID] = revocationEntry{cutoff: record.RevokedBefore, cachedAt: now}
+	r.mu.Unlock()
+	return record.RevokedBefore, nil
+}
+
+// sweep descarta entradas vencidas do cache; deve ser chamado com o lock.
+func sweep[K comparable](entries map[K]revocationEntry, now time.Time, ttl time.Duration) {
+	for key, entry := range entries {
+		if now.Sub(entry.cachedAt) >= ttl {
+			delete(entries, key)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestIsRevoked cobre as três formas de revogação: por token (jti), por
+// sessão e pelo corte de todos os tokens do usuário.
+func TestIsRevoked(t *testing.T) {
+	a := newTestApp(t)
+	store := a.r.Revocations
+	bob := a.createUser("bob")
+	now := time.Now()
+
+	active := &Session{UserID: bob.ID, FamilyID: "active", LastSeenAt: now}
+	revoked := &Session{UserID: bob.ID, FamilyID: "revoked", LastSeenAt: now}
+	a.r.GormStore.Create(active)
+	a.r.GormStore.Create(revoked)
+	if err := store.RevokeSession(revoked.ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeToken("revoked-jti", bob.ID, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	cutoff := now.Truncate(time.Second).Add(500 * time.Millisecond)
+	if err := store.RevokeUser(bob.ID, cutoff); err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(d time.Duration) *jwt.NumericDate {
+		return jwt.NewNumericDate(cutoff.Truncate(time.Second).Add(d))
+	}
+	cases := []struct {
+		name string
+		jti  string
+		sid  uint
+		iat  *jwt.NumericDate
+		want bool
+	}{
+		{"revoked jti", "revoked-jti", 0, at(time.Minute), true},
+		{"revoked session", "", revoked.ID, at(time.Minute), true},
+		{"before cutoff", "", 0, at(-time.Second), true},
+		{"same second as cutoff", "", 0, at(0), true},
+		{"same second with live session", "", active.ID, at(0), false},
+		{"before cutoff with live session", "", active.ID, at(-time.Second), true},
+		{"after cutoff", "", 0, at(time.Second), false},
+		{"without iat", "", 0, nil, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := &JwtClaims{Sub: bob.ID, Sid: tc.sid}
+			claims.ID = tc.jti
+			claims.IssuedAt = tc.iat
+			got, err := store.IsRevoked(claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("IsRevoked = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// roleParent é uma linha da tabela de junção roles_parents.
+type roleParent struct {
+	RoleID   uint
+	ParentID uint
+}
+
+// loadRoleParents preenche, in-place, a hierarquia completa de ancestrais das
+// roles, com as permissões de cada uma. Ciclos são impedidos na escrita, mas
+// também interrompidos aqui para não travar a leitura de dados legados.
+func loadRoleParents(db *gorm.DB, roles []Role) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	graph := make(map[uint][]uint)
+	visited := make(map[uint]bool)
+	var frontier []uint
+	for _, role := range roles {
+		if !visited[role.ID] {
+			visited[role.ID] = true
+			frontier = append(frontier, role.ID)
+		}
+	}
+
+	// Percorre a hierarquia em largura, um nível por consulta
+	for len(frontier) > 0 {
+		var edges []roleParent
+		if err := db.Table("roles_parents").
+			Where("role_id IN ?", frontier).
+			Find(&edges).Error; err != nil {
+			return fmt.Errorf("failed to query role parents: %w", err)
+		}
+		frontier = nil
+		for _, edge := range edges {
+			graph[edge.RoleID] = append(graph[edge.RoleID], edge.ParentID)
+			if !visited[edge.ParentID] {
+				visited[edge.ParentID] = true
+				frontier = append(frontier, edge.ParentID)
+			}
+		}
+	}
+	if len(graph) == 0 {
+		return nil
+	}
+
+	var ids []uint
+	for _, parents := range graph {
+		ids = append(ids, parents...)
+	}
+	var ancestors []Role
+	if err := db.Preload("Permissions").Where("id IN ?", ids).Find(&ancestors).Error; err != nil {
+		return fmt.Errorf("failed to fetch roles: %w", err)
+	}
+	byID := make(map[uint]Role, len(ancestors))
+	for _, role := range ancestors {
+		byID[role.ID] = role
+	}
+
+	var build func(id uint, path map[uint]bool) []Role
+	build = func(id uint, path map[uint]bool) []Role {
+		var parents []Role
+		for _, parentID := range graph[id] {
+			parent, ok := byID[parentID]
+			if !ok || path[parentID] {
+				continue
+			}
+			path[parentID] = true
+			parent.Parents = build(parentID, path)
+			delete(path, parentID)
+			parents = append(parents, parent)
+		}
+		return parents
+	}
+	for i := range roles {
+		roles[i].Parents = build(roles[i].ID, map[uint]bool{roles[i].ID: true})
+	}
+	return nil
+}
+
+// effectiveRoles retorna as roles ativas e seus ancestrais ativos, sem
+// repetição. Uma role inativa não transmite a herança.
+func effectiveRoles(roles []Role) []Role {
+	var result []Role
+	seen := make(map[uint]bool)
+	var walk func(roles []Role)
+	walk = func(roles []Role) {
+		for _, role := range roles {
+			if !role.Active || seen[role.ID] {
+				continue
+			}
+			seen[role.ID] = true
+			result = append(result, role)
+			walk(role.Parents)
+		}
+	}
+	walk(roles)
+	return result
+}
+
+// checkRoleCycle retorna erro se tornar parents pais da role criaria um
+// ciclo, ou seja, se a role já é ancestral (ou é) de algum deles.
+func checkRoleCycle(db *gorm.DB, roleID uint, parents []Role) error {
+	visited := make(map[uint]bool)
+	var frontier []uint
+	for _, parent := range parents {
+		if parent.ID == roleID {
+			return fmt.Errorf("role cannot inherit from itself")
+		}
+		if !visited[parent.ID] {
+			visited[parent.ID] = true
+			frontier = append(frontier, parent.ID)
+		}
+	}
+	for len(frontier) > 0 {
+		var edges []roleParent
+		if err := db.Table("roles_parents").
+			Where("role_id IN ?", frontier).
+			Find(&edges).Error; err != nil {
+			return fmt.Errorf("failed to query role parents: %w", err)
+		}
+		frontier = nil
+		for _, edge := range edges {
+			if edge.ParentID == roleID {
+				return fmt.Errorf("role hierarchy cycle: role '%v' is already an ancestor of its new parents", roleID)
+			}
+			if !visited[edge.ParentID] {
+				visited[edge.ParentID] = true
+				frontier = append(frontier, edge.ParentID)
+			}
+		}
+	}
+	return nil
+}
+
+// rolePermissions busca as permissões próprias da role. Uma role que só herda
+// de outras pode não ter permissões próprias.
+func (s *Service) rolePermissions(req *CreateRole) ([]Permission, error) {
+	var permissions []Permission
+	if len(req.Permissions) == 0 && len(req.Parents) > 0 {
+		return permissions, nil
+	}
+	if err := s.GetPermissionByIds(&permissions, req.Permissions); err != nil {
+		return nil, fmt.Errorf("permission with ids '%v' does not exist", req.Permissions)
+	}
+	return permissions, nil
+}
+
+// roleCodes retorna os códigos que a role concede, próprios ou herdados dos
+// pais, como se estivesse ativa.
+func (s *Service) roleCodes(permissions []Permission, parents []Role) ([]string, error) {
+	var ancestors []Role
+	if len(parents) > 0 {
+		if err := s.GormStore.Preload("Permissions").Where("id IN ?", roleIDs(parents)).Find(&ancestors).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch roles: %w", err)
+		}
+		if err := loadRoleParents(s.GormStore, ancestors); err != nil {
+			return nil, err
+		}
+	}
+	role := Role{Active: true, Permissions: permissions, Parents: ancestors}
+	return ExtractCodePermissionsByUser(&User{Roles: []Role{role}}), nil
+}
+
+// checkRoleDelegation exige que o editor já tenha cada permissão que a role
+// passa a conceder além das que concedia (before), sejam próprias ou herdadas
+// dos pais. Sem isso, quem edita roles ampliaria os próprios poderes.
+func (s *Service) checkRoleDelegation(editor *JwtClaims, before []string, permissions []Permission, parents []Role) error {
+	if editor.IsSuperUser {
+		return nil
+	}
+	after, err := s.roleCodes(permissions, parents)
+	if err != nil {
+		return err
+	}
+	for _, code := range after {
+		if slices.Contains(before, code) {
+			continue
+		}
+		if err := Authorize(editor, PermissionCode(code)); err != nil {
+			return fmt.Errorf("editor does not have permission '%s'", code)
+		}
+	}
+	return nil
+}
+
+func (s *Service) UpdateRole(editor *JwtClaims, id uint, req *CreateRole) (*Role, error) {
+	var role Role
+	if err := s.GormStore.
+		Preload("Permissions").
+		Preload("Parents").
+		Where("id = ? AND tenant_id = ?", id, s.tenantID).
+		First(&role).Error; err != nil {
+		return nil, fmt.Errorf("role with id '%v' does not exist", id)
+	}
+	before, err := s.roleCodes(role.Permissions, role.Parents)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.rolePermissions(req)
+	if err != nil {
+		return nil, err
+	}
+	parents, err := s.GetRoleByIds(req.Parents)
+	if err != nil {
+		return nil, fmt.Errorf("role with ids '%v' does not exist", req.Parents)
+	}
+	if err := checkRoleCycle(s.GormStore, role.ID, parents); err != nil {
+		return nil, err
+	}
+	if err := s.checkRoleDelegation(editor, before, permissions, parents); err != nil {
+		return nil, err
+	}
+
+	role.Name = req.Name
+	role.Description = req.Description
+	if err := s.GormStore.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Select("Name", "Description").Updates(&role).Error; err != nil {
+			return fmt.Errorf("failed to update role: %s", err.Error())
+		}
+		if err := tx.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+			return fmt.Errorf("failed to set permissions for role: %v", err)
+		}
+		if err := tx.Model(&role).Association("Parents").Replace(parents); err != nil {
+			return fmt.Errorf("failed to set parents for role: %v", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := s.Permissions.Bump(); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
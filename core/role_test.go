@@ -0,0 +1,159 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestCreateRole cobre a criação de roles com permissões próprias e/ou
+// herdadas, com o mesmo app para expor campos herdados da requisição anterior.
+func TestCreateRole(t *testing.T) {
+	a := newTestApp(t)
+	access, _ := a.login(testSuperUser, testSuperPass)
+	view := a.permission(PermissionViewUser)
+	base := a.createRole("base", PermissionViewUser)
+
+	cases := []struct {
+		name        string
+		body        map[string]any
+		status      int
+		wantParents int
+	}{
+		{"own permissions", map[string]any{"name": "own", "permissions": []uint{view.ID}}, fiber.StatusCreated, 0},
+		{"inherits only", map[string]any{"name": "heir", "parents": []uint{base.ID}}, fiber.StatusCreated, 1},
+		{"parents not reused", map[string]any{"name": "plain", "permissions": []uint{view.ID}}, fiber.StatusCreated, 0},
+		{"neither permissions nor parents", map[string]any{"name": "empty"}, fiber.StatusBadRequest, 0},
+		{"unknown parent", map[string]any{"name": "orphan", "parents": []uint{9999}}, fiber.StatusBadRequest, 0},
+		{"unknown permission", map[string]any{"name": "broken", "permissions": []uint{9999}}, fiber.StatusBadRequest, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, data, raw := a.do("POST", "/roles/", access, tc.body)
+			if status != tc.status {
+				t.Fatalf("status %d, want %d: %s", status, tc.status, raw)
+			}
+			if status != fiber.StatusCreated {
+				return
+			}
+			if parents, _ := data["parents"].([]any); len(parents) != tc.wantParents {
+				t.Fatalf("parents = %v, want %d", data["parents"], tc.wantParents)
+			}
+		})
+	}
+}
+
+// TestRoleCycle garante que a hierarquia de roles continua acíclica.
+func TestRoleCycle(t *testing.T) {
+	a := newTestApp(t)
+	service := a.r.Controller.Service
+	view := a.permission(PermissionViewUser)
+	root := a.createRole("root", PermissionViewUser)
+	middle := a.createRole("middle", PermissionViewUser)
+	leaf := a.createRole("leaf", PermissionViewUser)
+	a.r.GormStore.Model(&middle).Association("Parents").Replace([]Role{root})
+	a.r.GormStore.Model(&leaf).Association("Parents").Replace([]Role{middle})
+
+	cases := []struct {
+		name    string
+		role    Role
+		parents []uint
+		wantErr bool
+	}{
+		{"self", root, []uint{root.ID}, true},
+		{"direct cycle", root, []uint{middle.ID}, true},
+		{"transitive cycle", root, []uint{leaf.ID}, true},
+		{"new ancestor", leaf, []uint{root.ID}, false},
+		{"no parents", middle, nil, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := service.UpdateRole(&JwtClaims{IsSuperUser: true}, tc.role.ID, &CreateRole{Name: tc.role.Name, Permissions: []uint{view.ID}, Parents: tc.parents})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// TestRoleInheritance garante que o usuário recebe as permissões das roles
+// ancestrais ativas.
+func TestRoleInheritance(t *testing.T) {
+	cases := []struct {
+		name         string
+		parentActive bool
+		want         string
+	}{
+		{"active parent", true, "[users:view roles:view]"},
+		{"inactive parent", false, "[users:view]"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t)
+			parent := a.createRole("parent", PermissionViewRole)
+			child := a.createRole("child", PermissionViewUser)
+			a.r.GormStore.Model(&child).Association("Parents").Replace([]Role{parent})
+			if !tc.parentActive {
+				a.r.GormStore.Model(&parent).Update("active", false)
+			}
+			a.createUser("bob", child)
+
+			access, _ := a.login("bob", testPassword)
+			_, data, raw := a.do("GET", "/auth/me", access, nil)
+			if got := fmt.Sprint(data["permissions"]); got != tc.want {
+				t.Fatalf("permissions = %s, want %s: %s", got, tc.want, raw)
+			}
+		})
+	}
+}
+
+// TestRoleDelegation garante que quem edita roles não amplia os próprios
+// poderes: só pode acrescentar permissões, próprias ou herdadas, que já tem.
+func TestRoleDelegation(t *testing.T) {
+	a := newTestApp(t)
+	editor := a.createRole("editor", PermissionCreateRole, PermissionUpdateRole)
+	viewer := a.createRole("viewer", PermissionViewUser)
+	power := a.createRole("power", PermissionEditePermissionsUser)
+	a.createUser("eve", editor, viewer)
+	access, _ := a.login("eve", testPassword)
+	ids := func(codes ...PermissionCode) []uint {
+		var result []uint
+		for _, code := range codes {
+			result = append(result, a.permission(code).ID)
+		}
+		return result
+	}
+	own := []PermissionCode{PermissionCreateRole, PermissionUpdateRole}
+	editorPath := fmt.Sprintf("/roles/%d", editor.ID)
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   map[string]any
+		status int
+	}{
+		{"add permission not held", "PUT", editorPath, map[string]any{"name": "editor", "permissions": ids(append(own, PermissionEditePermissionsUser)...)}, fiber.StatusBadRequest},
+		{"add parent with permission not held", "PUT", editorPath, map[string]any{"name": "editor", "permissions": ids(own...), "parents": []uint{power.ID}}, fiber.StatusBadRequest},
+		{"create role with permission not held", "POST", "/roles/", map[string]any{"name": "rogue", "permissions": ids(PermissionEditePermissionsUser)}, fiber.StatusBadRequest},
+		{"keep current permissions", "PUT", editorPath, map[string]any{"name": "editor", "description": "renamed", "permissions": ids(own...)}, fiber.StatusOK},
+		{"add permission held", "PUT", editorPath, map[string]any{"name": "editor", "permissions": ids(append(own, PermissionViewUser)...)}, fiber.StatusOK},
+		{"add parent with permissions held", "PUT", editorPath, map[string]any{"name": "editor", "permissions": ids(own...), "parents": []uint{viewer.ID}}, fiber.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _, raw := a.do(tc.method, tc.path, access, tc.body)
+			if status != tc.status {
+				t.Fatalf("status %d, want %d: %s", status, tc.status, raw)
+			}
+		})
+	}
+
+	access, _ = a.login("eve", testPassword)
+	_, data, raw := a.do("GET", "/auth/me", access, nil)
+	if strings.Contains(fmt.Sprint(data["permissions"]), string(PermissionEditePermissionsUser)) {
+		t.Fatalf("escalated: %s", raw)
+	}
+}
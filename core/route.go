@@ -4,10 +4,12 @@ import "github.com/gofiber/fiber/v2"
 
 func (r *Router) RegisterRouter(router fiber.Router) {
 	r.Health(router.Group("/health"))
+	r.WellKnown(router.Group("/.well-known"))
 	r.Auth(router.Group("/auth", Limited(10)))
 	r.User(router.Group("/users"))
 	r.Role(router.Group("/roles"))
 	r.Permission(router.Group("/permissions"))
+	r.Tenant(router.Group("/tenants"))
 }
 
 func (r *Router) Health(router fiber.Router) {
@@ -17,56 +19,287 @@ func (r *Router) Health(router fiber.Router) {
 	)
 }
 
+func (r *Router) WellKnown(router fiber.Router) {
+	router.Get(
+		"/jwks.json",
+		r.Controller.JwksHandler,
+	)
+}
+
 func (r *Router) Auth(router fiber.Router) {
 	router.Post(
 		"/login",
 		ValidationMiddleware(&Login{}),
 		r.Controller.LoginHandler,
 	)
+	router.Post(
+		"/login/mfa",
+		ValidationMiddleware(&LoginMfa{}),
+		r.Controller.LoginMfaHandler,
+	)
+	router.Get(
+		"/oidc/:provider/login",
+		r.Controller.OidcLoginHandler,
+	)
+	router.Get(
+		"/oidc/:provider/callback",
+		ValidationMiddleware(&OidcCallback{}),
+		r.Controller.OidcCallbackHandler,
+	)
+	router.Post(
+		"/refresh",
+		ValidationMiddleware(&Refresh{}),
+		r.Controller.RefreshHandler,
+	)
+	router.Post(
+		"/password/forgot",
+		ValidationMiddleware(&ForgotPassword{}),
+		r.Controller.ForgotPasswordHandler,
+	)
+	router.Post(
+		"/password/reset",
+		ValidationMiddleware(&ResetPassword{}),
+		r.Controller.ResetPasswordHandler,
+	)
+	router.Post(
+		"/password/expired",
+		ValidationMiddleware(&ChangeExpiredPassword{}),
+		r.Controller.ChangeExpiredPasswordHandler,
+	)
+	router.Post(
+		"/password/change",
+		ValidationMiddleware(&ChangePassword{}),
+		r.JWTProtected(),
+		DenyApiKey(),
+		DenyImpersonation(),
+		r.Controller.ChangePasswordHandler,
+	)
+	router.Post(
+		"/logout",
+		r.JWTProtected(),
+		DenyApiKey(),
+		r.Controller.LogoutHandler,
+	)
+	router.Post(
+		"/impersonate/:id",
+		ValidationMiddleware(&UserParam{}),
+		r.JWTProtected(),
+		DenyApiKey(),
+		DenyImpersonation(),
+		r.Controller.ImpersonateHandler,
+	)
+	router.Get(
+		"/tenants",
+		r.JWTProtected(),
+		DenyApiKey(),
+		r.Controller.ListMyTenantsHandler,
+	)
+	router.Post(
+		"/tenants/:id/switch",
+		ValidationMiddleware(&TenantParam{}),
+		r.JWTProtected(),
+		DenyApiKey(),
+		DenyImpersonation(),
+		r.Controller.SwitchTenantHandler,
+	)
+	router.Get(
+		"/me",
+		r.JWTProtected(),
+		r.Controller.MeHandler,
+	)
+	router.Get(
+		"/sessions",
+		r.JWTProtected(),
+		DenyApiKey(),
+		r.Controller.ListMySessionsHandler,
+	)
+	router.Delete(
+		"/sessions",
+		r.JWTProtected(),
+		DenyApiKey(),
+		DenyImpersonation(),
+		r.Controller.RevokeMySessionsHandler,
+	)
+	router.Delete(
+		"/sessions/:id",
+		ValidationMiddleware(&SessionParam{}),
+		r.JWTProtected(),
+		DenyApiKey(),
+		DenyImpersonation(),
+		r.Controller.RevokeMySessionHandler,
+	)
+	router.Post(
+		"/mfa/enroll",
+		r.JWTProtected(),
+		DenyApiKey(),
+		DenyImpersonation(),
+		r.Controller.EnrollMfaHandler,
+	)
+	router.Post(
+		"/mfa/verify",
+		ValidationMiddleware(&MfaCode{}),
+		r.JWTProtected(),
+		DenyApiKey(),
+		DenyImpersonation(),
+		r.Controller.ConfirmMfaHandler,
+	)
+	router.Post(
+		"/mfa/disable",
+		ValidationMiddleware(&MfaCode{}),
+		r.JWTProtected(),
+		DenyApiKey(),
+		DenyImpersonation(),
+		r.Controller.DisableMfaHandler,
+	)
+	router.Get(
+		"/api-keys",
+		r.JWTProtected(),
+		DenyApiKey(),
+		r.Controller.ListApiKeysHandler,
+	)
+	router.Post(
+		"/api-keys",
+		ValidationMiddleware(&CreateApiKey{}),
+		r.JWTProtected(),
+		DenyApiKey(),
+		DenyImpersonation(),
+		r.Controller.CreateApiKeyHandler,
+	)
+	router.Delete(
+		"/api-keys/:id",
+		ValidationMiddleware(&ApiKeyParam{}),
+		r.JWTProtected(),
+		DenyApiKey(),
+		DenyImpersonation(),
+		r.Controller.RevokeApiKeyHandler,
+	)
 }
 
 func (r *Router) User(router fiber.Router) {
 	router.Get(
 		"/",
 		ValidationMiddleware(&Paginate{}),
-		JWTProtected(r.Jwt.JwtSecret),
+		r.JWTProtected(),
 		r.Controller.ListUserHandler,
 	)
 	router.Post(
 		"/",
 		ValidationMiddleware(&CreateUser{}),
-		JWTProtected(r.Jwt.JwtSecret, PermissionCreateUser),
+		r.JWTProtected(PermissionCreateUser),
 		r.Controller.CreateUserHandler,
 	)
 	router.Put(
 		"/:id",
 		ValidationMiddleware(&UserParam{}),
 		ValidationMiddleware(&UserSchema{}),
-		JWTProtected(r.Jwt.JwtSecret, PermissionUpdateUser),
+		r.JWTProtected(PermissionUpdateUser),
+		r.RequirePolicy(PolicyUpdate, r.UserResource),
 		r.Controller.UpdateUserHandler,
 	)
+	router.Get(
+		"/:id/permissions",
+		ValidationMiddleware(&UserParam{}),
+		r.JWTProtected(PermissionEditePermissionsUser),
+		r.Controller.ListUserPermissionsHandler,
+	)
+	router.Put(
+		"/:id/permissions",
+		ValidationMiddleware(&UserParam{}),
+		ValidationMiddleware(&SetUserPermission{}),
+		r.JWTProtected(PermissionEditePermissionsUser),
+		DenyImpersonation(),
+		r.Controller.SetUserPermissionHandler,
+	)
+	router.Delete(
+		"/:id/permissions/:permission_id",
+		ValidationMiddleware(&UserPermissionParam{}),
+		r.JWTProtected(PermissionEditePermissionsUser),
+		DenyImpersonation(),
+		r.Controller.DeleteUserPermissionHandler,
+	)
+	router.Post(
+		"/:id/revoke-tokens",
+		ValidationMiddleware(&UserParam{}),
+		r.JWTProtected(PermissionUpdateUser),
+		DenyImpersonation(),
+		r.Controller.RevokeUserTokensHandler,
+	)
+	router.Post(
+		"/:id/unlock",
+		ValidationMiddleware(&UserParam{}),
+		r.JWTProtected(),
+		r.Controller.UnlockUserHandler,
+	)
+	router.Get(
+		"/:id/sessions",
+		ValidationMiddleware(&UserParam{}),
+		r.JWTProtected(),
+		r.Controller.ListUserSessionsHandler,
+	)
+	router.Delete(
+		"/:id/sessions",
+		ValidationMiddleware(&UserParam{}),
+		r.JWTProtected(),
+		DenyImpersonation(),
+		r.Controller.RevokeUserSessionsHandler,
+	)
+	router.Delete(
+		"/:id/sessions/:session_id",
+		ValidationMiddleware(&UserSessionParam{}),
+		r.JWTProtected(),
+		DenyImpersonation(),
+		r.Controller.RevokeUserSessionHandler,
+	)
 }
 
 func (r *Router) Role(router fiber.Router) {
 	router.Get(
 		"/",
 		ValidationMiddleware(&Paginate{}),
-		JWTProtected(r.Jwt.JwtSecret),
+		r.JWTProtected(),
 		r.Controller.ListRoleHandler,
 	)
 	router.Post(
 		"/",
 		ValidationMiddleware(&CreateRole{}),
-		JWTProtected(r.Jwt.JwtSecret, PermissionCreateRole),
+		r.JWTProtected(PermissionCreateRole),
 		r.Controller.CreateRoleHandler,
 	)
+	router.Put(
+		"/:id",
+		ValidationMiddleware(&CreateRole{}),
+		r.JWTProtected(PermissionUpdateRole),
+		r.Controller.UpdateRoleHandler,
+	)
+}
+
+func (r *Router) Tenant(router fiber.Router) {
+	router.Get(
+		"/",
+		ValidationMiddleware(&Paginate{}),
+		r.JWTProtected(PermissionViewTenant),
+		r.Controller.ListTenantHandler,
+	)
+	router.Post(
+		"/",
+		ValidationMiddleware(&CreateTenant{}),
+		r.JWTProtected(PermissionCreateTenant),
+		r.Controller.CreateTenantHandler,
+	)
+	router.Post(
+		"/:id/members",
+		ValidationMiddleware(&TenantParam{}),
+		ValidationMiddleware(&AddMember{}),
+		r.JWTProtected(PermissionUpdateTenant),
+		r.Controller.AddMemberHandler,
+	)
 }
 
 func (r *Router) Permission(router fiber.Router) {
 	router.Get(
 		"/",
 		ValidationMiddleware(&Paginate{}),
-		JWTProtected(r.Jwt.JwtSecret, PermissionEditePermissionsUser),
+		r.JWTProtected(PermissionEditePermissionsUser),
 		r.Controller.ListPermissiontHandler,
 	)
 }
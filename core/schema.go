@@ -1,5 +1,7 @@
 package core
 
+import "time"
+
 type HealthHandler struct {
 	Sql map[string]string `json:"sql"`
 }
@@ -14,6 +16,82 @@ type Login struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type LoginMfa struct {
+	MfaToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type MfaChallenge struct {
+	MfaRequired bool   `json:"mfa_required"`
+	MfaToken    string `json:"mfa_token"`
+}
+
+type PasswordChangeChallenge struct {
+	PasswordChangeRequired bool   `json:"password_change_required"`
+	PasswordChangeToken    string `json:"password_change_token"`
+	Reason                 string `json:"reason"`
+}
+
+type ChangeExpiredPassword struct {
+	PasswordChangeToken string `json:"password_change_token" validate:"required"`
+	NewPassword         string `json:"new_password" validate:"required"`
+}
+
+type ImpersonationSchema struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	UserID      uint   `json:"user_id"`
+	ActorID     uint   `json:"actor_id"`
+}
+
+type MfaCode struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type MfaEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type MfaRecovery struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type ChangePassword struct {
+	CurrentPassword     string `json:"current_password" validate:"required"`
+	NewPassword         string `json:"new_password" validate:"required"`
+	RevokeOtherSessions bool   `json:"revoke_other_sessions"`
+}
+
+type OidcCallback struct {
+	Code  string `query:"code" validate:"required_without=Error"`
+	State string `query:"state" validate:"required"`
+	Error string `query:"error"`
+}
+
+type Refresh struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type Logout struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ClientInfo identifica o cliente que iniciou ou renovou uma sessão.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
 type Token struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
@@ -23,16 +101,31 @@ type CreateRole struct {
 	Name        string `json:"name" validate:"required,min=3,max=100"`
 	Description string `json:"description"`
 	Permissions []uint `json:"permissions"`
+	Parents     []uint `json:"parents"`
+}
+
+type CreateTenant struct {
+	Name string `json:"name" validate:"required,min=3,max=100"`
+}
+
+type AddMember struct {
+	UserID uint   `json:"user_id" validate:"required"`
+	Roles  []uint `json:"roles"`
+}
+
+type SetUserPermission struct {
+	PermissionID uint             `json:"permission_id" validate:"required"`
+	Effect       PermissionEffect `json:"effect" validate:"required,oneof=allow deny"`
 }
 
 type CreateUser struct {
 	UserSchema
-	Password string `json:"password" validate:"required,min=6"`
+	Password string `json:"password" validate:"required"`
 }
 
 // DTO model
 type UserParam struct {
-	ID uint `param:"id"`
+	ID uint `params:"id"`
 }
 type UserSchema struct {
 	ID          uint   `json:"id"`
@@ -40,18 +133,89 @@ type UserSchema struct {
 	LastName    string `json:"lastName" validate:"omitempty,max=50"`
 	Username    string `json:"username" validate:"required,min=3,max=50"`
 	Email       string `json:"email" validate:"required,email"`
-	Active      bool   `json:"active"`
+	Active      *bool  `json:"active"` // ausente na atualização mantém o valor atual
 	IsSuperUser bool   `json:"isSuperUser"`
 	Roles       []uint `json:"roles"`
 	Phone1      string `json:"phone1" validate:"required,e164"`
 	Phone2      string `json:"phone2" validate:"omitempty,e164"`
 }
 
+type MeSchema struct {
+	UserSchema
+	TenantID    uint     `json:"tenantId"`
+	Permissions []string `json:"permissions"`
+	Denies      []string `json:"denies"`
+	RoleNames   []string `json:"roleNames"`
+}
+
+type CreateApiKey struct {
+	Name        string     `json:"name" validate:"required,min=3,max=100"`
+	Permissions []string   `json:"permissions" validate:"required,min=1"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+}
+
+type ApiKeyParam struct {
+	ID uint `params:"id"`
+}
+
+type ApiKeySchema struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Key         string     `json:"key,omitempty"` // apenas na criação
+	Permissions []string   `json:"permissions"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+}
+
+type UserPermissionParam struct {
+	ID           uint `params:"id"`
+	PermissionID uint `params:"permission_id"`
+}
+
+type UserPermissionSchema struct {
+	PermissionID uint             `json:"permissionId"`
+	Code         string           `json:"code"`
+	Effect       PermissionEffect `json:"effect"`
+}
+
+type TenantParam struct {
+	ID uint `params:"id"`
+}
+
+type TenantSchema struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Active    bool      `json:"active"`
+	Current   bool      `json:"current"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type SessionParam struct {
+	ID uint `params:"id"`
+}
+
+type UserSessionParam struct {
+	ID        uint `params:"id"`
+	SessionID uint `params:"session_id"`
+}
+
+type SessionSchema struct {
+	ID         uint      `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"`
+}
+
 type RoleSchema struct {
 	ID          uint               `json:"id"`
 	Name        string             `json:"name"`
 	Description string             `json:"description"`
 	Permissions []PermissionSchema `json:"permissions"`
+	Parents     []uint             `json:"parents"`
 }
 
 type PermissionSchema struct {
@@ -10,21 +10,23 @@ import (
 )
 
 func (a *AppConfig) SaveUserAdmin() error {
-	hashPassword, err := HashPassword(a.Super.SuperPass)
+	hashPassword, err := a.HashPassword(a.Super.SuperPass)
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %s", err.Error())
 	}
+	var user User
 	if err := a.GormStore.
-		FirstOrCreate(&User{
+		Where(User{Username: a.Super.SuperUser}).
+		Attrs(User{
 			FirstName:   a.Super.SuperName,
 			LastName:    "Admin",
-			Username:    a.Super.SuperUser,
 			Email:       a.Super.SuperEmail,
 			Password:    hashPassword,
 			Active:      true,
 			IsSuperUser: true,
 			Phone1:      a.Super.SuperPhone,
-		}).Error; err != nil {
+		}).
+		FirstOrCreate(&user).Error; err != nil {
 		return err
 	}
 	return nil
@@ -32,8 +34,11 @@ func (a *AppConfig) SaveUserAdmin() error {
 
 func (a *AppConfig) SavePermissions(permissions ...PermissionCode) error {
 	for _, permission := range permissions {
+		var item Permission
 		if err := a.GormStore.
-			FirstOrCreate(&Permission{Code: string(permission)}).
+			Where(Permission{Code: string(permission)}).
+			Attrs(Permission{Name: string(permission), Active: true}).
+			FirstOrCreate(&item).
 			Error; err != nil {
 			return err
 		}
@@ -52,7 +57,7 @@ func (s *AppConfig) SeedUserAdmin() error {
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return fmt.Errorf("failed to check admin existence: %s", err.Error())
 	}
-	hashPassword, err := HashPassword(s.Super.SuperPass)
+	hashPassword, err := s.HashPassword(s.Super.SuperPass)
 	if err != nil {
 		return fmt.Errorf("failed to create admin: %s", err.Error())
 	}
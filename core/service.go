@@ -2,26 +2,76 @@ package core
 
 import (
 	"fmt"
+	"log"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-func (s *Service) Login(req *Login) (*User, error) {
+func (s *Service) Login(req *Login, client ClientInfo) (*User, error) {
+	if err := s.checkIPLockout(client.IP); err != nil {
+		return nil, err
+	}
+	// O bloqueio por identificador vale igualmente para contas inexistentes
+	if err := s.checkIdentifierLockout(req.Username); err != nil {
+		return nil, err
+	}
+
+	// O hash é sempre verificado, mesmo sem usuário, para que o tempo de
+	// resposta não revele quais contas existem
 	var user User
 	result := s.GormStore.
-		Preload("Roles.Permissions").
 		Where("username = ? OR email = ?", req.Username, req.Username).
 		First(&user)
 	if result.Error != nil {
-		return nil, fmt.Errorf("failed to login: username or password is incorrect")
+		s.CheckPassword(req.Password, s.dummyHash)
+		if err := s.registerLoginFailure(nil, req.Username, client); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+	valid := s.CheckPassword(req.Password, user.Password)
+	if err := s.checkUserLockout(&user); err != nil {
+		return nil, err
+	}
+	if !valid {
+		if err := s.registerLoginFailure(&user, req.Username, client); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+	if err := s.resetIdentifierFailures(req.Username); err != nil {
+		return nil, err
+	}
+	// Com MFA, o contador só é zerado após o segundo fator (LoginMfa)
+	if !user.MfaEnabled {
+		if err := s.resetLoginFailures(&user); err != nil {
+			return nil, err
+		}
 	}
-	if !CheckPasswordHash(req.Password, user.Password) {
-		return nil, fmt.Errorf("failed to login: username or password is incorrect")
+	if s.hasher().NeedsRehash(user.Password) {
+		s.rehashPassword(&user, req.Password)
 	}
 	if !user.Active {
+		if !s.Auth.RevealInactive {
+			return nil, ErrInvalidCredentials
+		}
 		return nil, fmt.Errorf("failed to login: user is inactive")
 	}
-	return &user, nil
+	return s.GetUserByID(user.ID)
+}
+
+// rehashPassword migra o hash para o algoritmo e parâmetros atuais. Falhas não
+// impedem o login: a migração é tentada novamente no próximo acesso.
+func (s *Service) rehashPassword(user *User, password string) {
+	hashedPassword, err := s.HashPassword(password)
+	if err != nil {
+		log.Printf("failed to rehash password for user %d: %s", user.ID, err.Error())
+		return
+	}
+	if err := s.GormStore.Model(user).Update("password", hashedPassword).Error; err != nil {
+		log.Printf("failed to rehash password for user %d: %s", user.ID, err.Error())
+	}
 }
 
 func (s *Service) ListPermission(permissions *[]Permission) error {
@@ -54,6 +104,8 @@ func (s *Service) GetPermissionByIds(permissions *[]Permission, ids []uint) erro
 func (s *Service) ListRole(roles *[]Role) error {
 	result := s.GormStore.
 		Preload("Permissions").
+		Preload("Parents").
+		Where("tenant_id = ?", s.tenantID).
 		Find(roles)
 	if result.Error != nil {
 		return fmt.Errorf("failed to query database: %w", result.Error)
@@ -61,20 +113,30 @@ func (s *Service) ListRole(roles *[]Role) error {
 	return nil
 }
 
-func (s *Service) CreateRole(role *Role, req *CreateRole) error {
-	var permissions []Permission
-	if err := s.GetPermissionByIds(&permissions, req.Permissions); err != nil {
-		return fmt.Errorf("permission with ids '%v' does not exist", req.Permissions)
+func (s *Service) CreateRole(creator *JwtClaims, role *Role, req *CreateRole) error {
+	permissions, err := s.rolePermissions(req)
+	if err != nil {
+		return err
+	}
+
+	parents, err := s.GetRoleByIds(req.Parents)
+	if err != nil {
+		return fmt.Errorf("role with ids '%v' does not exist", req.Parents)
+	}
+	if err := s.checkRoleDelegation(creator, nil, permissions, parents); err != nil {
+		return err
 	}
 
+	role.TenantID = s.tenantID
 	role.Name = req.Name
 	role.Permissions = permissions // Associar permissões à role
+	role.Parents = parents         // Roles herdadas; uma role nova não tem filhas, então não há ciclo
 	role.Description = req.Description
 
 	if err := s.GormStore.Create(role).Error; err != nil {
 		return fmt.Errorf("failed to create role: %s", err.Error())
 	}
-	return nil
+	return s.Permissions.Bump()
 }
 
 func (s *Service) GetRoleByIds(ids []uint) ([]Role, error) {
@@ -84,7 +146,7 @@ func (s *Service) GetRoleByIds(ids []uint) ([]Role, error) {
 	}
 
 	// Buscar as permissões pelos IDs fornecidos
-	if err := s.GormStore.Where("id IN ?", ids).Find(&roles).Error; err != nil {
+	if err := s.GormStore.Where("id IN ? AND tenant_id = ?", ids, s.tenantID).Find(&roles).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch roles: %s", err.Error())
 	}
 
@@ -101,6 +163,7 @@ func (s *Service) GetUserByID(id uint) (*User, error) {
 	result := s.GormStore.
 		Where("id = ?", id).
 		Preload("Roles.Permissions").
+		Preload("PermissionOverrides.Permission").
 		First(&user)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
@@ -108,9 +171,26 @@ func (s *Service) GetUserByID(id uint) (*User, error) {
 		}
 		return nil, fmt.Errorf("failed to query database: %w", result.Error)
 	}
+	if err := loadRoleParents(s.GormStore, user.Roles); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
+// Me carrega o usuário autenticado direto do banco, para que permissões e
+// roles reflitam o estado atual e não o snapshot do token. No escopo de um
+// tenant, as roles do vínculo são somadas às globais.
+func (s *Service) Me(userID uint) (*User, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Active {
+		return nil, fmt.Errorf("user is inactive")
+	}
+	return withTenantRoles(s.GormStore, user, s.tenantID)
+}
+
 func (s *Service) CreateUser(creatorID uint, req *CreateUser) (*User, error) {
 	// Buscar o criador do usuário
 	creator, err := s.GetUserByID(creatorID)
@@ -125,18 +205,23 @@ func (s *Service) CreateUser(creatorID uint, req *CreateUser) (*User, error) {
 	}
 
 	// Validar se o criador possui as roles necessárias ou é superusuário
-	if !creator.IsSuperUser && !ContainsAll(creator.Roles, roles) {
+	creatorRoles, err := s.scopedRoles(creator)
+	if err != nil {
+		return nil, err
+	}
+	if !creator.IsSuperUser && !ContainsAll(creatorRoles, roles) {
 		return nil, fmt.Errorf("failed to create user: creator does not have all required roles")
 	}
 
 	// Criar o usuário (apenas em memória)
+	now := time.Now().In(s.TimeUCT)
 	user := User{
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
 		Username:  req.Username,
 		Email:     req.Email,
 		Password:  req.Password,
-		Active:    req.Active,
+		Active:    req.Active != nil && *req.Active,
 		IsSuperUser: func() bool {
 			if req.IsSuperUser {
 				if creator.IsSuperUser {
@@ -148,6 +233,9 @@ func (s *Service) CreateUser(creatorID uint, req *CreateUser) (*User, error) {
 		}(),
 		Phone1: req.Phone1,
 		Phone2: req.Phone2,
+		// A senha foi definida pelo criador: o usuário deve trocá-la no primeiro login
+		MustChangePassword: true,
+		PasswordChangedAt:  &now,
 	}
 
 	// Persistir o usuário no banco de dados
@@ -155,9 +243,12 @@ func (s *Service) CreateUser(creatorID uint, req *CreateUser) (*User, error) {
 		return nil, fmt.Errorf("failed to create user")
 	}
 
-	// Associar as roles ao usuário
-	if err := s.GormStore.Model(&user).Association("Roles").Replace(roles); err != nil {
-		return nil, fmt.Errorf("failed to set roles for user")
+	// Associar as roles ao usuário (no tenant, quando houver escopo)
+	if err := s.setScopedRoles(&user, roles); err != nil {
+		return nil, err
+	}
+	if err := s.Permissions.Bump(); err != nil {
+		return nil, err
 	}
 
 	// Retornar o usuário criado
@@ -169,18 +260,25 @@ func (s *Service) UpdateUser(editorID uint, id uint, req *UserSchema) (*User, er
 	if err != nil {
 		return nil, fmt.Errorf("user modified with id '%v' does not exists", id)
 	}
+	if member, err := s.isScopedUser(id); err != nil || !member {
+		return nil, fmt.Errorf("user modified with id '%v' does not exists", id)
+	}
 	// var editor models.User
 	editor, err := s.GetUserByID(editorID)
 	if err != nil {
 		return nil, fmt.Errorf("user editor with id '%v' does not exist", editorID)
 	}
-	// Se o editor não tiver permissão para atualizar o usuário, ele só pode atualizar a si mesmo
-	if !editor.IsSuperUser && user.ID != editorID {
-		return nil, fmt.Errorf("user editor with id '%v' does not have permission to update user with id '%v'", editorID, id)
+	subject, err := s.subjectClaims(editor)
+	if err != nil {
+		return nil, err
+	}
+	resource := userResource(user)
+	if err := s.Can(subject, PolicyUpdate, resource); err != nil {
+		return nil, err
 	}
-	// Se o editor tiver permissão para atualizar o usuário, atualize o usuário
-	if editor.IsSuperUser {
-		if err := s.UpdateFullUser(user, editor, req); err != nil {
+	// Roles, identificação e superusuário exigem a política PolicyAdminister
+	if s.Can(subject, PolicyAdminister, resource) == nil {
+		if err := s.UpdateFullUser(editor, user, req); err != nil {
 			return nil, err
 		}
 	} else {
@@ -189,20 +287,25 @@ func (s *Service) UpdateUser(editorID uint, id uint, req *UserSchema) (*User, er
 			return nil, err
 		}
 	}
+	if err := s.Permissions.Bump(); err != nil {
+		return nil, err
+	}
 
 	return user, nil
 }
 
 func (s *Service) UpdateSimpleUser(user *User, req *UserSchema) error {
 	// Atualizar outros campos do usuário
+	// Active fica de fora: o próprio usuário não se reativa
 	user.FirstName = req.FirstName
 	user.LastName = req.LastName
-	user.Active = req.Active
 	user.Phone1 = req.Phone1
 	user.Phone2 = req.Phone2
 
-	// Salvar as alterações
-	if err := s.GormStore.Model(user).Updates(user).Error; err != nil {
+	// Salvar as alterações (Select inclui campos zerados, como Phone2 vazio)
+	if err := s.GormStore.Model(user).
+		Select("FirstName", "LastName", "Phone1", "Phone2").
+		Updates(user).Error; err != nil {
 		return fmt.Errorf("failed to update user: %s", err.Error())
 	}
 	return nil
@@ -218,23 +321,45 @@ func (s *Service) UpdateFullUser(editor *User, user *User, req *UserSchema) erro
 		}
 		// Validar se o criador possui as roles necessárias ou é superusuário
 		if !editor.IsSuperUser {
-			if !ContainsAll(editor.Roles, roles) {
+			editorRoles, err := s.scopedRoles(editor)
+			if err != nil {
+				return err
+			}
+			if !ContainsAll(editorRoles, roles) {
 				return fmt.Errorf("failed to update user: editor does not have all required roles")
 			}
 		}
 
-		// Atualizar as roles do usuário
-		if err := s.GormStore.Model(user).Association("Roles").Replace(roles); err != nil {
-			return fmt.Errorf("failed to set roles for user: %v", err)
+		// Atualizar as roles do usuário (no tenant, quando houver escopo)
+		if err := s.setScopedRoles(user, roles); err != nil {
+			return err
+		}
+	}
+
+	// No escopo de um tenant, o admin do tenant só altera o vínculo: os
+	// campos globais do usuário são compartilhados com os demais tenants
+	if s.tenantID != 0 && !editor.IsSuperUser {
+		if req.Active == nil {
+			return nil
+		}
+		if err := s.GormStore.Model(&Membership{}).
+			Where("user_id = ? AND tenant_id = ?", user.ID, s.tenantID).
+			Update("active", *req.Active).Error; err != nil {
+			return fmt.Errorf("failed to update membership: %s", err.Error())
 		}
+		return nil
 	}
 
 	// Atualizar outros campos do usuário
+	fields := []string{"FirstName", "LastName", "Username", "Email", "IsSuperUser", "Phone1", "Phone2"}
 	user.FirstName = req.FirstName
 	user.LastName = req.LastName
 	user.Username = req.Username
 	user.Email = req.Email
-	user.Active = req.Active
+	if req.Active != nil {
+		user.Active = *req.Active
+		fields = append(fields, "Active")
+	}
 	if req.IsSuperUser {
 		if !editor.IsSuperUser {
 			return fmt.Errorf("only superusers can update other superusers")
@@ -244,8 +369,10 @@ func (s *Service) UpdateFullUser(editor *User, user *User, req *UserSchema) erro
 	user.Phone1 = req.Phone1
 	user.Phone2 = req.Phone2
 
-	// Salvar as alterações
-	if err := s.GormStore.Model(user).Updates(user).Error; err != nil {
+	// Salvar as alterações (Select inclui campos zerados, como Active=false)
+	if err := s.GormStore.Model(user).
+		Select(fields).
+		Updates(user).Error; err != nil {
 		return fmt.Errorf("failed to update user: %s", err.Error())
 	}
 	return nil
@@ -253,9 +380,13 @@ func (s *Service) UpdateFullUser(editor *User, user *User, req *UserSchema) erro
 
 func (s *Service) ListUser() ([]User, error) {
 	var users []User
-	result := s.GormStore.
-		Preload("Roles.Permissions").
-		Find(&users)
+	query := s.GormStore.Preload("Roles.Permissions")
+	if s.tenantID != 0 {
+		query = query.Where("id IN (?)", s.GormStore.Model(&Membership{}).
+			Select("user_id").
+			Where("tenant_id = ? AND active = ?", s.tenantID, true))
+	}
+	result := query.Find(&users)
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to query database list: %w", result.Error)
 	}
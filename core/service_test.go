@@ -0,0 +1,125 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestUpdateUserActive garante que active só muda quando enviado, e nunca
+// pelo próprio usuário.
+func TestUpdateUserActive(t *testing.T) {
+	cases := []struct {
+		name       string
+		self       bool
+		active     any
+		wantActive bool
+	}{
+		{"admin omits active", false, nil, true},
+		{"admin deactivates", false, false, false},
+		{"admin keeps active", false, true, true},
+		{"self omits active", true, nil, true},
+		{"self cannot deactivate", true, false, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t)
+			// users:update sem PolicyAdminister: só a atualização simples de si mesmo
+			bob := a.createUser("bob", a.createRole("editor", PermissionUpdateUser))
+			token, _ := a.login(testSuperUser, testSuperPass)
+			if tc.self {
+				token, _ = a.login("bob", testPassword)
+			}
+			body := map[string]any{
+				"firstName": "Robert",
+				"username":  "bob",
+				"email":     "bob@example.com",
+				"phone1":    "+5511911112222",
+			}
+			if tc.active != nil {
+				body["active"] = tc.active
+			}
+			status, _, raw := a.do("PUT", fmt.Sprintf("/users/%d", bob.ID), token, body)
+			if status != fiber.StatusOK {
+				t.Fatalf("update: %d %s", status, raw)
+			}
+			user, err := a.r.Controller.Service.GetUserByID(bob.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.FirstName != "Robert" || user.Active != tc.wantActive {
+				t.Fatalf("firstName = %s, active = %v, want active %v", user.FirstName, user.Active, tc.wantActive)
+			}
+		})
+	}
+}
+
+// verifyRecorder registra os hashes conferidos no login.
+type verifyRecorder struct {
+	BcryptHasher
+	hashes *[]string
+}
+
+func (h verifyRecorder) Verify(password, hash string) bool {
+	*h.hashes = append(*h.hashes, hash)
+	return h.BcryptHasher.Verify(password, hash)
+}
+
+// TestLoginUnknownUserChecksHash garante que o login de um usuário inexistente
+// confere o hash fictício e responde como uma senha errada.
+func TestLoginUnknownUserChecksHash(t *testing.T) {
+	var hashes []string
+	a := newTestApp(t, func(c *AppConfig) {
+		c.Hasher = verifyRecorder{BcryptHasher: BcryptHasher{Cost: 4}, hashes: &hashes}
+	})
+	bob := a.createUser("bob")
+	cases := []struct {
+		name     string
+		username string
+		wantHash string
+	}{
+		{"wrong password", "bob", bob.Password},
+		{"unknown user", "ghost", a.r.Controller.Service.dummyHash},
+	}
+	var bodies []string
+	for _, tc := range cases {
+		hashes = nil
+		status, _, raw := a.do("POST", "/auth/login", "", map[string]any{"username": tc.username, "password": "Wrong@123"})
+		if status != fiber.StatusBadRequest {
+			t.Fatalf("%s: %d %s", tc.name, status, raw)
+		}
+		if len(hashes) != 1 || hashes[0] != tc.wantHash {
+			t.Fatalf("%s: verified %v, want %s", tc.name, hashes, tc.wantHash)
+		}
+		bodies = append(bodies, raw)
+	}
+	if bodies[0] != bodies[1] {
+		t.Fatalf("responses differ: %q vs %q", bodies[0], bodies[1])
+	}
+}
+
+// TestLoginRevealInactive garante que o login de um usuário inativo só é
+// identificado como tal com Auth.RevealInactive.
+func TestLoginRevealInactive(t *testing.T) {
+	cases := []struct {
+		name     string
+		reveal   bool
+		wantBody string
+	}{
+		{"generic response", false, ErrInvalidCredentials.Error()},
+		{"revealed", true, "failed to login: user is inactive"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t, func(c *AppConfig) { c.Auth.RevealInactive = tc.reveal })
+			bob := a.createUser("bob")
+			a.r.GormStore.Model(bob).Update("active", false)
+
+			status, _, raw := a.do("POST", "/auth/login", "", map[string]any{"username": "bob", "password": testPassword})
+			if status != fiber.StatusBadRequest || raw != tc.wantBody {
+				t.Fatalf("%d %s, want %q", status, raw, tc.wantBody)
+			}
+		})
+	}
+}
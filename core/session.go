@@ -0,0 +1,354 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// StartSession registra uma nova sessão para o usuário e emite o primeiro par
+// de tokens dela.
+func (s *Service) StartSession(user *User, client ClientInfo) (*Token, error) {
+	familyID, err := NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := s.defaultTenant(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().In(s.TimeUCT)
+	session := &Session{
+		UserID:     user.ID,
+		TenantID:   tenantID,
+		FamilyID:   familyID,
+		UserAgent:  truncate(client.UserAgent, 255),
+		IP:         truncate(client.IP, 45),
+		LastSeenAt: now,
+	}
+	if err := s.GormStore.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("failed to create session: %s", err.Error())
+	}
+	return s.IssueTokens(user, session)
+}
+
+// IssueTokens gera um novo par access/refresh para o usuário e registra o
+// refresh token na família da sessão.
+func (s *Service) IssueTokens(user *User, session *Session) (*Token, error) {
+	jti, err := NewTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	scoped, err := withTenantRoles(s.GormStore, user, session.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	permissions := ExtractCodePermissionsByUser(scoped)
+	denies := ExtractDeniedCodesByUser(scoped)
+
+	accessToken, err := GenerateToken(&GenToken{
+		Id:          user.ID,
+		Sid:         session.ID,
+		Tid:         session.TenantID,
+		Type:        TokenAccess,
+		AppName:     s.Jwt.AppName,
+		Audience:    []string{s.Jwt.GetAudience()},
+		Permissions: permissions,
+		Denies:      denies,
+		IsSuperUser: user.IsSuperUser,
+		TimeZone:    s.Jwt.TimeZone,
+		JwtSecret:   s.Jwt.JwtSecret,
+		Key:         s.Jwt.SigningKey(),
+		Ttl:         s.Jwt.JwtExpireAccess,
+	})
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := GenerateToken(&GenToken{
+		Id:          user.ID,
+		Jti:         jti,
+		Sid:         session.ID,
+		Tid:         session.TenantID,
+		Type:        TokenRefresh,
+		AppName:     s.Jwt.AppName,
+		Audience:    []string{s.Jwt.GetAudience()},
+		Permissions: permissions,
+		Denies:      denies,
+		IsSuperUser: user.IsSuperUser,
+		TimeZone:    s.Jwt.TimeZone,
+		JwtSecret:   s.Jwt.JwtSecret,
+		Key:         s.Jwt.SigningKey(),
+		Ttl:         s.Jwt.JwtExpireRefresh,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	record := &RefreshToken{
+		Jti:       jti,
+		FamilyID:  session.FamilyID,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().In(s.TimeUCT).Add(s.Jwt.JwtExpireRefresh),
+	}
+	if err := s.GormStore.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %s", err.Error())
+	}
+
+	return &Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// RefreshTokens troca um refresh token válido por um novo par. O token
+// apresentado é consumido; se ele já tiver sido usado ou revogado, toda a
+// família (e a sessão) é revogada.
+func (s *Service) RefreshTokens(req *Refresh, client ClientInfo) (*Token, error) {
+	claims, err := s.Jwt.VerifyRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh token: %s", err.Error())
+	}
+	if claims.ID == "" {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+
+	var record RefreshToken
+	if err := s.GormStore.Where("jti = ?", claims.ID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("invalid refresh token")
+		}
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+
+	now := time.Now().In(s.TimeUCT)
+	if record.UsedAt != nil {
+		if err := s.RevokeTokenFamily(record.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("refresh token reuse detected: session revoked")
+	}
+	if record.RevokedAt != nil {
+		return nil, fmt.Errorf("refresh token revoked")
+	}
+	if now.After(record.ExpiresAt) {
+		return nil, fmt.Errorf("refresh token expired")
+	}
+
+	// Consome o token de forma atômica para que duas requisições concorrentes
+	// com o mesmo token não recebam pares novos.
+	result := s.GormStore.Model(&RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", record.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to consume refresh token: %s", result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		if err := s.RevokeTokenFamily(record.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("refresh token reuse detected: session revoked")
+	}
+
+	var session Session
+	if err := s.GormStore.Where("family_id = ?", record.FamilyID).First(&session).Error; err != nil {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+	if session.RevokedAt != nil {
+		return nil, fmt.Errorf("session revoked")
+	}
+
+	user, err := s.GetUserByID(record.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+	if !user.Active {
+		if err := s.RevokeSession(&session); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to refresh: user is inactive")
+	}
+
+	// O último acesso da sessão é atualizado a cada renovação de tokens
+	if err := s.GormStore.Model(&session).Updates(Session{
+		LastSeenAt: now,
+		IP:         truncate(client.IP, 45),
+		UserAgent:  truncate(client.UserAgent, 255),
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update session: %s", err.Error())
+	}
+
+	return s.IssueTokens(user, &session)
+}
+
+// RevokeTokenFamily revoga a sessão dona da família de refresh tokens.
+func (s *Service) RevokeTokenFamily(familyID string) error {
+	var session Session
+	if err := s.GormStore.Where("family_id = ?", familyID).First(&session).Error; err != nil {
+		return fmt.Errorf("failed to find session: %s", err.Error())
+	}
+	return s.RevokeSession(&session)
+}
+
+// RevokeSession encerra a sessão: revoga seus refresh tokens e invalida os
+// access tokens emitidos para ela.
+func (s *Service) RevokeSession(session *Session) error {
+	now := time.Now().In(s.TimeUCT)
+	if err := s.GormStore.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", session.FamilyID).
+		Update("revoked_at", now).Error; err != nil {
+		return fmt.Errorf("failed to revoke token family: %s", err.Error())
+	}
+	if err := s.Revocations.RevokeSession(session.ID, now); err != nil {
+		return err
+	}
+	session.RevokedAt = &now
+	return nil
+}
+
+// Logout revoga o access token atual e a sessão a que ele pertence. Tokens
+// sem sessão podem informar o refresh token para revogar a sua família.
+func (s *Service) Logout(claims *JwtClaims, req *Logout) error {
+	expiresAt := time.Unix(int64(claims.Exp), 0)
+	if err := s.Revocations.RevokeToken(claims.ID, claims.Sub, expiresAt); err != nil {
+		return err
+	}
+	if claims.Sid != 0 {
+		session, err := s.getSession(claims.Sub, claims.Sid)
+		if err != nil {
+			return err
+		}
+		return s.RevokeSession(session)
+	}
+	if req.RefreshToken == "" {
+		return nil
+	}
+
+	refresh, err := s.Jwt.VerifyRefreshToken(req.RefreshToken)
+	if err != nil || refresh.Sub != claims.Sub {
+		return fmt.Errorf("invalid refresh token")
+	}
+	var record RefreshToken
+	if err := s.GormStore.Where("jti = ?", refresh.ID).First(&record).Error; err != nil {
+		return fmt.Errorf("invalid refresh token")
+	}
+	return s.RevokeTokenFamily(record.FamilyID)
+}
+
+// RevokeUserTokens invalida todos os access e refresh tokens já emitidos para
+// o usuário. Apenas superusuários podem revogar tokens de outros usuários.
+func (s *Service) RevokeUserTokens(editorID uint, id uint) error {
+	if err := s.canManageUser(editorID, id); err != nil {
+		return err
+	}
+	return s.revokeAllTokens(id)
+}
+
+func (s *Service) revokeAllTokens(userID uint) error {
+	now := time.Now().In(s.TimeUCT)
+	if err := s.Revocations.RevokeUser(userID, now); err != nil {
+		return err
+	}
+	if err := s.GormStore.Model(&RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", now).Error; err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %s", err.Error())
+	}
+	return s.revokeOtherSessions(userID, 0)
+}
+
+// revokeOtherSessions encerra as sessões ativas do usuário, exceto keepID.
+func (s *Service) revokeOtherSessions(userID uint, keepID uint) error {
+	var sessions []Session
+	if err := s.GormStore.
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Find(&sessions).Error; err != nil {
+		return fmt.Errorf("failed to query database list: %w", err)
+	}
+	for i := range sessions {
+		if err := s.RevokeSession(&sessions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSessions lista as sessões ativas do usuário.
+func (s *Service) ListSessions(editorID uint, userID uint) ([]Session, error) {
+	if err := s.canManageUser(editorID, userID); err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	if err := s.GormStore.
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database list: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeUserSession encerra uma sessão específica do usuário.
+func (s *Service) RevokeUserSession(editorID uint, userID uint, sessionID uint) error {
+	if err := s.canManageUser(editorID, userID); err != nil {
+		return err
+	}
+	session, err := s.getSession(userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+	return s.RevokeSession(session)
+}
+
+// RevokeUserSessions encerra todas as sessões do usuário.
+func (s *Service) RevokeUserSessions(editorID uint, userID uint) error {
+	return s.RevokeUserTokens(editorID, userID)
+}
+
+func (s *Service) getSession(userID uint, sessionID uint) (*Session, error) {
+	var session Session
+	if err := s.GormStore.
+		Where("id = ? AND user_id = ?", sessionID, userID).
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("no session found for id: %d", sessionID)
+		}
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+	return &session, nil
+}
+
+// canManageUser aplica a política PolicyUpdate sobre o usuário: por padrão,
+// cada um gerencia a própria conta e superusuários gerenciam qualquer conta.
+func (s *Service) canManageUser(editorID uint, userID uint) error {
+	editor, err := s.GetUserByID(editorID)
+	if err != nil {
+		return fmt.Errorf("user editor with id '%v' does not exist", editorID)
+	}
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("user with id '%v' does not exist", userID)
+	}
+	subject, err := s.subjectClaims(editor)
+	if err != nil {
+		return err
+	}
+	return s.Can(subject, PolicyUpdate, userResource(user))
+}
+
+// truncate limita value a size bytes sem cortar um caractere multibyte.
+func truncate(value string, size int) string {
+	if len(value) <= size {
+		return value
+	}
+	for size > 0 && !utf8.RuneStart(value[size]) {
+		size--
+	}
+	return value[:size]
+}
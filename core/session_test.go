@@ -0,0 +1,120 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRefreshRotation(t *testing.T) {
+	a := newTestApp(t)
+	access, refresh := a.login(testSuperUser, testSuperPass)
+
+	status, data, raw := a.do("POST", "/auth/refresh", "", map[string]any{"refresh_token": refresh})
+	if status != fiber.StatusOK {
+		t.Fatalf("refresh: %d %s", status, raw)
+	}
+	rotated := data["refresh_token"].(string)
+	if rotated == refresh {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	// Reuso do token consumido revoga a família inteira
+	if status, _, raw := a.do("POST", "/auth/refresh", "", map[string]any{"refresh_token": refresh}); status != fiber.StatusUnauthorized {
+		t.Fatalf("reuse: %d %s", status, raw)
+	}
+	if status, _, raw := a.do("POST", "/auth/refresh", "", map[string]any{"refresh_token": rotated}); status != fiber.StatusUnauthorized {
+		t.Fatalf("family survived reuse: %s", raw)
+	}
+	if status, _, _ := a.do("GET", "/auth/me", access, nil); status != fiber.StatusUnauthorized {
+		t.Fatalf("access token survived reuse: %d", status)
+	}
+}
+
+// TestRefreshNoTokenReplay garante que uma requisição sem refresh token não
+// aproveita o token da requisição anterior.
+func TestRefreshNoTokenReplay(t *testing.T) {
+	a := newTestApp(t)
+	_, refresh := a.login(testSuperUser, testSuperPass)
+
+	if status, _, raw := a.do("POST", "/auth/refresh", "", map[string]any{"refresh_token": refresh}); status != fiber.StatusOK {
+		t.Fatalf("refresh: %d %s", status, raw)
+	}
+	if status, _, raw := a.do("POST", "/auth/refresh", "", map[string]any{}); status != fiber.StatusBadRequest {
+		t.Fatalf("empty body: %d %s", status, raw)
+	}
+}
+
+func TestRefreshRejectsAccessToken(t *testing.T) {
+	a := newTestApp(t)
+	access, refresh := a.login(testSuperUser, testSuperPass)
+
+	if status, _, raw := a.do("POST", "/auth/refresh", "", map[string]any{"refresh_token": access}); status != fiber.StatusUnauthorized {
+		t.Fatalf("access token accepted as refresh token: %s", raw)
+	}
+	if status, _, _ := a.do("GET", "/auth/me", refresh, nil); status != fiber.StatusUnauthorized {
+		t.Fatalf("refresh token accepted as access token: %d", status)
+	}
+}
+
+// TestImpersonationCannotEndSessions garante que um token de personificação
+// não encerra as sessões nem revoga os tokens do usuário personificado.
+func TestImpersonationCannotEndSessions(t *testing.T) {
+	a := newTestApp(t)
+	bob := a.createUser("bob", a.createRole("editor", PermissionUpdateUser))
+	admin, _ := a.login(testSuperUser, testSuperPass)
+	bobAccess, _ := a.login("bob", testPassword)
+	var session Session
+	a.r.GormStore.Where("user_id = ?", bob.ID).First(&session)
+
+	status, data, raw := a.do("POST", fmt.Sprintf("/auth/impersonate/%d", bob.ID), admin, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("impersonate: %d %s", status, raw)
+	}
+	impersonation := data["access_token"].(string)
+
+	cases := []struct {
+		method string
+		path   string
+	}{
+		{"DELETE", fmt.Sprintf("/auth/sessions/%d", session.ID)},
+		{"DELETE", fmt.Sprintf("/users/%d/sessions", bob.ID)},
+		{"DELETE", fmt.Sprintf("/users/%d/sessions/%d", bob.ID, session.ID)},
+		{"POST", fmt.Sprintf("/users/%d/revoke-tokens", bob.ID)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			if status, _, raw := a.do(tc.method, tc.path, impersonation, nil); status != fiber.StatusForbidden {
+				t.Fatalf("status %d: %s", status, raw)
+			}
+		})
+	}
+	if status, _, raw := a.do("GET", "/auth/me", bobAccess, nil); status != fiber.StatusOK {
+		t.Fatalf("session ended by impersonator: %d %s", status, raw)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		value string
+		size  int
+		want  string
+	}{
+		{"Mozilla", 10, "Mozilla"},
+		{"Mozilla", 3, "Moz"},
+		{"ação", 2, "a"},  // "ç" tem 2 bytes
+		{"ação", 3, "aç"}, // limite exato do caractere
+		{"日本語", 4, "日"},   // 3 bytes por caractere
+		{"日本語", 2, ""},    // nenhum caractere inteiro cabe
+		{"a😀b", 4, "a"},   // emoji de 4 bytes
+		{"a😀b", 5, "a😀"},
+	}
+	for _, tc := range cases {
+		got := truncate(tc.value, tc.size)
+		if got != tc.want || !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tc.value, tc.size, got, tc.want)
+		}
+	}
+}
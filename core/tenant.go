@@ -0,0 +1,295 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotTenantMember indica que o usuário não tem vínculo ativo com o tenant.
+var ErrNotTenantMember = errors.New("user is not a member of the tenant")
+
+// ErrTenantRequired indica um token sem tenant de um usuário que é membro de
+// algum tenant: ele precisa trocar para um dos seus tenants.
+var ErrTenantRequired = errors.New("tenant required: switch to one of your tenants")
+
+// ForTenant retorna uma cópia do serviço cujas consultas (ListUser, ListRole,
+// GetRoleByIds) e criações ficam restritas ao tenant. Zero é o escopo global,
+// sem tenant.
+func (s *Service) ForTenant(tenantID uint) *Service {
+	scoped := *s
+	scoped.tenantID = tenantID
+	return &scoped
+}
+
+// ForClaims retorna o serviço restrito ao tenant dos claims. Sem tenant, o
+// escopo global só vale para o superusuário e para usuários sem vínculo com
+// tenants; os demais recebem ErrTenantRequired.
+func (s *Service) ForClaims(claims *JwtClaims) (*Service, error) {
+	if claims.Tid != 0 || claims.IsSuperUser {
+		return s.ForTenant(claims.Tid), nil
+	}
+	var user User
+	if err := s.GormStore.Select("id", "is_super_user").First(&user, claims.Sub).Error; err != nil {
+		return nil, fmt.Errorf("user with id '%v' does not exist", claims.Sub)
+	}
+	if user.IsSuperUser {
+		return s.ForTenant(0), nil
+	}
+	var count int64
+	if err := s.GormStore.Model(&Membership{}).
+		Where("user_id = ? AND active = ?", user.ID, true).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+	if count > 0 {
+		return nil, ErrTenantRequired
+	}
+	return s.ForTenant(0), nil
+}
+
+// TenantID retorna o tenant do escopo atual.
+func (s *Service) TenantID() uint {
+	return s.tenantID
+}
+
+// withTenantRoles retorna uma cópia do usuário com as roles globais somadas
+// às roles do vínculo com o tenant, e apenas as permissões diretas globais ou
+// do tenant, para o cálculo de permissões.
+func withTenantRoles(db *gorm.DB, user *User, tenantID uint) (*User, error) {
+	scoped := scopeOverrides(user, tenantID)
+	if tenantID == 0 {
+		return scoped, nil
+	}
+	membership, err := findMembership(db, user.ID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	scoped.Roles = append(slices.Clone(user.Roles), membership.Roles...)
+	return scoped, nil
+}
+
+// scopeOverrides retorna uma cópia do usuário só com as permissões diretas
+// globais ou do tenant.
+func scopeOverrides(user *User, tenantID uint) *User {
+	scoped := *user
+	scoped.PermissionOverrides = nil
+	for _, override := range user.PermissionOverrides {
+		if override.TenantID == 0 || override.TenantID == tenantID {
+			scoped.PermissionOverrides = append(scoped.PermissionOverrides, override)
+		}
+	}
+	return &scoped
+}
+
+func findMembership(db *gorm.DB, userID uint, tenantID uint) (*Membership, error) {
+	var membership Membership
+	err := db.
+		Preload("Tenant").
+		Preload("Roles.Permissions").
+		Where("user_id = ? AND tenant_id = ?", userID, tenantID).
+		First(&membership).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotTenantMember
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+	if !membership.Active || !membership.Tenant.Active {
+		return nil, ErrNotTenantMember
+	}
+	if err := loadRoleParents(db, membership.Roles); err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// defaultTenant escolhe o tenant inicial da sessão: o primeiro vínculo ativo
+// do usuário, ou zero se não houver.
+func (s *Service) defaultTenant(userID uint) (uint, error) {
+	var memberships []Membership
+	if err := s.GormStore.
+		Preload("Tenant").
+		Where("user_id = ? AND active = ?", userID, true).
+		Order("id").
+		Find(&memberships).Error; err != nil {
+		return 0, fmt.Errorf("failed to query database: %w", err)
+	}
+	for _, membership := range memberships {
+		if membership.Tenant.Active {
+			return membership.TenantID, nil
+		}
+	}
+	return 0, nil
+}
+
+// scopedRoles retorna as roles do usuário no escopo atual: globais sem
+// tenant, ou as do vínculo com o tenant.
+func (s *Service) scopedRoles(user *User) ([]Role, error) {
+	if s.tenantID == 0 {
+		return user.Roles, nil
+	}
+	membership, err := findMembership(s.GormStore, user.ID, s.tenantID)
+	if errors.Is(err, ErrNotTenantMember) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return membership.Roles, nil
+}
+
+// setScopedRoles substitui as roles do usuário no escopo atual, criando o
+// vínculo com o tenant se necessário.
+func (s *Service) setScopedRoles(user *User, roles []Role) error {
+	if s.tenantID == 0 {
+		if err := s.GormStore.Model(user).Association("Roles").Replace(roles); err != nil {
+			return fmt.Errorf("failed to set roles for user: %v", err)
+		}
+		return nil
+	}
+	membership := Membership{UserID: user.ID, TenantID: s.tenantID}
+	if err := s.GormStore.
+		Where(Membership{UserID: user.ID, TenantID: s.tenantID}).
+		Attrs(Membership{Active: true}).
+		FirstOrCreate(&membership).Error; err != nil {
+		return fmt.Errorf("failed to create membership: %s", err.Error())
+	}
+	if err := s.GormStore.Model(&membership).Association("Roles").Replace(roles); err != nil {
+		return fmt.Errorf("failed to set roles for user: %v", err)
+	}
+	return nil
+}
+
+// isScopedUser informa se o usuário pertence ao escopo atual.
+func (s *Service) isScopedUser(userID uint) (bool, error) {
+	if s.tenantID == 0 {
+		return true, nil
+	}
+	var count int64
+	if err := s.GormStore.Model(&Membership{}).
+		Where("user_id = ? AND tenant_id = ?", userID, s.tenantID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to query database: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *Service) ListTenant() ([]Tenant, error) {
+	var tenants []Tenant
+	if err := s.GormStore.Order("id").Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+	return tenants, nil
+}
+
+func (s *Service) CreateTenant(req *CreateTenant) (*Tenant, error) {
+	tenant := &Tenant{Name: req.Name, Active: true}
+	if err := s.GormStore.Create(tenant).Error; err != nil {
+		return nil, fmt.Errorf("failed to create tenant: %s", err.Error())
+	}
+	return tenant, nil
+}
+
+// AddMember vincula o usuário ao tenant com as roles informadas, que devem
+// pertencer ao tenant. Fora do superusuário, só o tenant ativo do editor pode
+// ser alterado e só usuários sem vínculo com outros tenants podem ser
+// adicionados.
+func (s *Service) AddMember(claims *JwtClaims, tenantID uint, req *AddMember) error {
+	if !claims.IsSuperUser && claims.Tid != tenantID {
+		return fmt.Errorf("cannot manage members of tenant '%v'", tenantID)
+	}
+	var tenant Tenant
+	if err := s.GormStore.Where("id = ?", tenantID).First(&tenant).Error; err != nil {
+		return fmt.Errorf("tenant with id '%v' does not exist", tenantID)
+	}
+	user, err := s.GetUserByID(req.UserID)
+	if err != nil {
+		return fmt.Errorf("user with id '%v' does not exist", req.UserID)
+	}
+	// Fora do superusuário, só é possível vincular usuários sem vínculo com
+	// outro tenant: um admin de tenant não traz contas alheias para o seu
+	if !claims.IsSuperUser {
+		if user.IsSuperUser {
+			return fmt.Errorf("cannot add superuser '%v' as member", user.ID)
+		}
+		var others int64
+		if err := s.GormStore.Model(&Membership{}).
+			Where("user_id = ? AND tenant_id <> ?", user.ID, tenantID).
+			Count(&others).Error; err != nil {
+			return fmt.Errorf("failed to query database: %w", err)
+		}
+		if others > 0 {
+			return fmt.Errorf("user '%v' belongs to another tenant", user.ID)
+		}
+	}
+	scoped := s.ForTenant(tenantID)
+	roles, err := scoped.GetRoleByIds(req.Roles)
+	if err != nil {
+		return fmt.Errorf("role with ids '%v' does not exist", req.Roles)
+	}
+	// Fora do superusuário, só é possível delegar roles que o editor possui
+	if !claims.IsSuperUser {
+		editor, err := s.GetUserByID(claims.Sub)
+		if err != nil {
+			return fmt.Errorf("user with id '%v' does not exist", claims.Sub)
+		}
+		editorRoles, err := scoped.scopedRoles(editor)
+		if err != nil {
+			return err
+		}
+		if !ContainsAll(editorRoles, roles) {
+			return fmt.Errorf("failed to add member: editor does not have all required roles")
+		}
+	}
+	if err := scoped.setScopedRoles(user, roles); err != nil {
+		return err
+	}
+	return s.Permissions.Bump()
+}
+
+// ListMyTenants lista os tenants com vínculo ativo do usuário.
+func (s *Service) ListMyTenants(userID uint) ([]Tenant, error) {
+	var tenants []Tenant
+	if err := s.GormStore.
+		Joins("JOIN memberships ON memberships.tenant_id = tenants.id AND memberships.deleted_at IS NULL").
+		Where("memberships.user_id = ? AND memberships.active = ? AND tenants.active = ?", userID, true, true).
+		Order("tenants.id").
+		Find(&tenants).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+	return tenants, nil
+}
+
+// SwitchTenant troca o tenant ativo da sessão e emite um novo par de tokens.
+// O access token atual é revogado.
+func (s *Service) SwitchTenant(claims *JwtClaims, tenantID uint) (*Token, error) {
+	if claims.Sid == 0 {
+		return nil, fmt.Errorf("tenant switch requires a session token")
+	}
+	user, err := s.GetUserByID(claims.Sub)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := findMembership(s.GormStore, user.ID, tenantID); err != nil {
+		return nil, err
+	}
+	session, err := s.getSession(claims.Sub, claims.Sid)
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, fmt.Errorf("session revoked")
+	}
+	if err := s.GormStore.Model(session).Update("tenant_id", tenantID).Error; err != nil {
+		return nil, fmt.Errorf("failed to update session: %s", err.Error())
+	}
+	expiresAt := time.Unix(int64(claims.Exp), 0)
+	if err := s.Revocations.RevokeToken(claims.ID, claims.Sub, expiresAt); err != nil {
+		return nil, err
+	}
+	return s.IssueTokens(user, session)
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// tenantAdmin cria um admin de tenant (sem superusuário) e retorna o token
+// dele já no escopo do tenant.
+func (a *testApp) tenantAdmin(tenant *Tenant, username string, codes ...PermissionCode) (*User, string) {
+	a.t.Helper()
+	role := Role{TenantID: tenant.ID, Name: username + "-admin", Active: true}
+	for _, code := range codes {
+		role.Permissions = append(role.Permissions, a.permission(code))
+	}
+	if err := a.r.GormStore.Create(&role).Error; err != nil {
+		a.t.Fatal(err)
+	}
+	user := a.createUser(username)
+	a.addMember(tenant, user, role)
+	access, _ := a.login(username, testPassword)
+	status, data, raw := a.do("POST", fmt.Sprintf("/auth/tenants/%d/switch", tenant.ID), access, nil)
+	if status != fiber.StatusOK {
+		a.t.Fatalf("switch: %d %s", status, raw)
+	}
+	return user, data["access_token"].(string)
+}
+
+// TestAddMemberConsent garante que um admin de tenant só vincula usuários
+// sem vínculo com outros tenants; o superusuário vincula qualquer um.
+func TestAddMemberConsent(t *testing.T) {
+	a := newTestApp(t)
+	acme := a.createTenant("Acme")
+	globex := a.createTenant("Globex")
+	_, acmeAdmin := a.tenantAdmin(acme, "carol", PermissionUpdateTenant)
+	super, _ := a.login(testSuperUser, testSuperPass)
+
+	fresh := a.createUser("fresh")
+	member := a.createUser("member")
+	a.addMember(acme, member)
+	foreign := a.createUser("foreign")
+	a.addMember(globex, foreign)
+	adopted := a.createUser("adopted")
+	a.addMember(globex, adopted)
+
+	cases := []struct {
+		name   string
+		token  string
+		user   *User
+		status int
+	}{
+		{"fresh user", acmeAdmin, fresh, fiber.StatusNoContent},
+		{"existing member", acmeAdmin, member, fiber.StatusNoContent},
+		{"member of another tenant", acmeAdmin, foreign, fiber.StatusBadRequest},
+		{"superuser target", acmeAdmin, a.superUser(), fiber.StatusBadRequest},
+		{"superuser editor", super, adopted, fiber.StatusNoContent},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := fmt.Sprintf("/tenants/%d/members", acme.ID)
+			status, _, raw := a.do("POST", path, tc.token, map[string]any{"user_id": tc.user.ID})
+			if status != tc.status {
+				t.Fatalf("status %d, want %d: %s", status, tc.status, raw)
+			}
+		})
+	}
+
+	var count int64
+	a.r.GormStore.Model(&Membership{}).Where("user_id = ? AND tenant_id = ?", foreign.ID, acme.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("foreign user was pulled into the tenant")
+	}
+}
+
+// TestTenantScopedUpdateKeepsGlobalFields garante que o admin de um tenant
+// altera só o vínculo do usuário, e não os dados compartilhados entre tenants.
+func TestTenantScopedUpdateKeepsGlobalFields(t *testing.T) {
+	a := newTestApp(t)
+	a.r.Policies.(*Policies).Register(ResourceUser, PolicyUpdate, AllowPermission(PermissionUpdateUser))
+	a.r.Policies.(*Policies).Register(ResourceUser, PolicyAdminister, AllowPermission(PermissionUpdateUser))
+	acme := a.createTenant("Acme")
+	globex := a.createTenant("Globex")
+	_, acmeAdmin := a.tenantAdmin(acme, "carol", PermissionUpdateUser, PermissionViewUser)
+	bob := a.createUser("bob")
+	a.addMember(acme, bob)
+	a.addMember(globex, bob)
+
+	status, _, raw := a.do("PUT", fmt.Sprintf("/users/%d", bob.ID), acmeAdmin, map[string]any{
+		"firstName": "Hacked",
+		"username":  "hacked",
+		"email":     "hacked@example.com",
+		"phone1":    "+5511911112222",
+		"active":    false,
+	})
+	if status != fiber.StatusOK {
+		t.Fatalf("update: %d %s", status, raw)
+	}
+
+	user, err := a.r.Controller.Service.GetUserByID(bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "bob" || user.Email != "bob@example.com" || user.FirstName != "bob" || !user.Active {
+		t.Fatalf("global fields changed: %+v", user)
+	}
+	var memberships []Membership
+	a.r.GormStore.Where("user_id = ?", bob.ID).Order("tenant_id").Find(&memberships)
+	if len(memberships) != 2 || memberships[0].Active || !memberships[1].Active {
+		t.Fatalf("want only the Acme membership deactivated: %+v", memberships)
+	}
+
+	// O vínculo inativo some da listagem do tenant
+	status, _, raw = a.do("GET", "/users/?page=1&limit=10", acmeAdmin, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("list: %d %s", status, raw)
+	}
+	if !strings.Contains(raw, `"username":"carol"`) || strings.Contains(raw, `"username":"bob"`) {
+		t.Fatalf("want only active members listed: %s", raw)
+	}
+}
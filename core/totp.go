@@ -0,0 +1,91 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parâmetros TOTP (RFC 6238) compatíveis com os aplicativos autenticadores.
+const (
+	TotpPeriod = 30 * time.Second
+	TotpDigits = 6
+	TotpSkew   = 1 // janelas aceitas antes e depois da atual
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTotpSecret gera um segredo de 160 bits codificado em base32.
+func GenerateTotpSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate totp secret: %s", err.Error())
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TotpProvisioningURI monta a URI otpauth:// usada nos QR codes de cadastro.
+func TotpProvisioningURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TotpDigits))
+	query.Set("period", fmt.Sprint(int(TotpPeriod.Seconds())))
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// TotpStep retorna a janela de tempo TOTP de t.
+func TotpStep(t time.Time) int64 {
+	return t.Unix() / int64(TotpPeriod.Seconds())
+}
+
+// TotpCode calcula o código da janela step (RFC 4226, seção 5.3).
+func TotpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("invalid totp secret")
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range TotpDigits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TotpDigits, value%mod), nil
+}
+
+// ValidateTotp verifica o código nas janelas vizinhas a t e retorna a janela
+// aceita, para que o chamador possa impedir o reuso do mesmo código.
+func ValidateTotp(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != TotpDigits {
+		return 0, false
+	}
+	current := TotpStep(t)
+	for step := current - TotpSkew; step <= current+TotpSkew; step++ {
+		expected, err := TotpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
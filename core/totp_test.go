@@ -0,0 +1,76 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+// Segredo "12345678901234567890" dos vetores de teste da RFC 6238 (SHA1).
+const rfcTotpSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestTotpCode confere os vetores da RFC 6238, apêndice B, com 6 dígitos.
+func TestTotpCode(t *testing.T) {
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tc := range cases {
+		got, err := TotpCode(rfcTotpSecret, TotpStep(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Fatalf("T=%d: code %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+	if _, err := TotpCode("not base32!", 1); err == nil {
+		t.Fatal("invalid secret accepted")
+	}
+}
+
+// TestValidateTotp cobre a tolerância de janelas e a normalização do código.
+func TestValidateTotp(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := TotpStep(now)
+	code := func(offset int64) string {
+		c, err := TotpCode(rfcTotpSecret, step+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	cases := []struct {
+		name     string
+		secret   string
+		code     string
+		wantOk   bool
+		wantStep int64
+	}{
+		{"current step", rfcTotpSecret, code(0), true, step},
+		{"previous step", rfcTotpSecret, code(-1), true, step - 1},
+		{"next step", rfcTotpSecret, code(1), true, step + 1},
+		{"two steps behind", rfcTotpSecret, code(-2), false, 0},
+		{"two steps ahead", rfcTotpSecret, code(2), false, 0},
+		{"with spaces", rfcTotpSecret, code(0)[:3] + " " + code(0)[3:], true, step},
+		{"too short", rfcTotpSecret, code(0)[:5], false, 0},
+		{"too long", rfcTotpSecret, code(0) + "0", false, 0},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code(0), true, step},
+		{"invalid secret", "not base32!", code(0), false, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ValidateTotp(tc.secret, tc.code, now)
+			if ok != tc.wantOk || got != tc.wantStep {
+				t.Fatalf("ValidateTotp = (%d, %v), want (%d, %v)", got, ok, tc.wantStep, tc.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"fmt"
+)
+
+// PermissionEffect define se a permissão direta concede ou nega o acesso.
+type PermissionEffect string
+
+const (
+	PermissionAllow PermissionEffect = "allow"
+	PermissionDeny  PermissionEffect = "deny"
+)
+
+// IsDenied informa se required é negado por algum dos códigos em denied. Os
+// curingas valem, mas não as implicações: negar "users:update" não nega
+// "users:view".
+func IsDenied(denied []string, required PermissionCode) bool {
+	for _, code := range denied {
+		if matchPermission(code, string(required)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractDeniedCodesByUser retorna os códigos negados diretamente ao usuário.
+func ExtractDeniedCodesByUser(user *User) []string {
+	var codes []string
+	for _, override := range user.PermissionOverrides {
+		if override.Effect == PermissionDeny && override.Permission.Active {
+			codes = append(codes, override.Permission.Code)
+		}
+	}
+	return codes
+}
+
+func (s *Service) ListUserPermissions(id uint) ([]UserPermission, error) {
+	if member, err := s.isScopedUser(id); err != nil || !member {
+		return nil, fmt.Errorf("user with id '%v' does not exist", id)
+	}
+	var overrides []UserPermission
+	if err := s.GormStore.
+		Preload("Permission").
+		Where("user_id = ? AND tenant_id = ?", id, s.tenantID).
+		Order("id").
+		Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+	return overrides, nil
+}
+
+// SetUserPermission concede ou nega uma permissão diretamente ao usuário,
+// substituindo o efeito anterior. No escopo de um tenant, vale apenas nele e
+// exige que o usuário seja membro. Fora do superusuário, o editor só pode
+// conceder ou negar permissões que ele mesmo tem.
+func (s *Service) SetUserPermission(editor *JwtClaims, id uint, req *SetUserPermission) (*UserPermission, error) {
+	if req.Effect != PermissionAllow && req.Effect != PermissionDeny {
+		return nil, fmt.Errorf("invalid effect '%s'", req.Effect)
+	}
+	if !editor.IsSuperUser && editor.Tid != s.tenantID {
+		return nil, fmt.Errorf("cannot manage permissions of tenant '%v'", s.tenantID)
+	}
+	if _, err := s.GetUserByID(id); err != nil {
+		return nil, fmt.Errorf("user with id '%v' does not exist", id)
+	}
+	if member, err := s.isScopedUser(id); err != nil || !member {
+		return nil, fmt.Errorf("user with id '%v' does not exist", id)
+	}
+	var permission Permission
+	if err := s.GormStore.Where("id = ?", req.PermissionID).First(&permission).Error; err != nil {
+		return nil, fmt.Errorf("permission with id '%v' does not exist", req.PermissionID)
+	}
+	if err := Authorize(editor, PermissionCode(permission.Code)); err != nil {
+		return nil, fmt.Errorf("editor does not have permission '%s'", permission.Code)
+	}
+
+	override := UserPermission{UserID: id, TenantID: s.tenantID, PermissionID: permission.ID}
+	if err := s.GormStore.
+		Where("user_id = ? AND tenant_id = ? AND permission_id = ?", id, s.tenantID, permission.ID).
+		Assign(UserPermission{Effect: req.Effect}).
+		FirstOrCreate(&override).Error; err != nil {
+		return nil, fmt.Errorf("failed to set user permission: %s", err.Error())
+	}
+	override.Permission = permission
+	if err := s.Permissions.Bump(); err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+func (s *Service) DeleteUserPermission(editor *JwtClaims, id uint, permissionID uint) error {
+	if member, err := s.isScopedUser(id); err != nil || !member {
+		return fmt.Errorf("user with id '%v' does not exist", id)
+	}
+	var override UserPermission
+	if err := s.GormStore.
+		Preload("Permission").
+		Where("user_id = ? AND tenant_id = ? AND permission_id = ?", id, s.tenantID, permissionID).
+		First(&override).Error; err != nil {
+		return fmt.Errorf("user permission with id '%v' does not exist", permissionID)
+	}
+	if err := Authorize(editor, PermissionCode(override.Permission.Code)); err != nil {
+		return fmt.Errorf("editor does not have permission '%s'", override.Permission.Code)
+	}
+	if err := s.GormStore.Unscoped().Delete(&override).Error; err != nil {
+		return fmt.Errorf("failed to delete user permission: %s", err.Error())
+	}
+	return s.Permissions.Bump()
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestExtractPermissionsWithOverrides(t *testing.T) {
+	permission := func(code string) Permission {
+		return Permission{Code: code, Active: true}
+	}
+	override := func(code string, effect PermissionEffect) UserPermission {
+		return UserPermission{Permission: permission(code), Effect: effect}
+	}
+	role := Role{Active: true, Permissions: []Permission{permission("users:view"), permission("users:create")}}
+
+	tests := []struct {
+		name       string
+		overrides  []UserPermission
+		wantGrants []string
+		wantDenies []string
+	}{
+		{"roles only", nil, []string{"users:view", "users:create"}, nil},
+		{"direct allow", []UserPermission{override("roles:view", PermissionAllow)}, []string{"users:view", "users:create", "roles:view"}, nil},
+		{"deny removes role grant", []UserPermission{override("users:create", PermissionDeny)}, []string{"users:view"}, []string{"users:create"}},
+		{"deny beats direct allow", []UserPermission{override("roles:view", PermissionAllow), override("roles:view", PermissionDeny)}, []string{"users:view", "users:create"}, []string{"roles:view"}},
+		{"wildcard deny", []UserPermission{override("users:*", PermissionDeny)}, nil, []string{"users:*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &User{Roles: []Role{role}, PermissionOverrides: tt.overrides}
+			if got := ExtractCodePermissionsByUser(user); !slices.Equal(got, tt.wantGrants) {
+				t.Fatalf("grants = %v, want %v", got, tt.wantGrants)
+			}
+			if got := ExtractDeniedCodesByUser(user); !slices.Equal(got, tt.wantDenies) {
+				t.Fatalf("denies = %v, want %v", got, tt.wantDenies)
+			}
+		})
+	}
+}
+
+func TestDenyBeatsWildcardGrant(t *testing.T) {
+	claims := &JwtClaims{Permissions: []string{"users:*"}, Denies: []string{"users:create"}}
+	if PermissionCreateUser.SatisfiedBy(claims) {
+		t.Fatal("deny ignored")
+	}
+	if !PermissionViewUser.SatisfiedBy(claims) {
+		t.Fatal("unrelated permission denied")
+	}
+	// Negar update não nega view, ainda que update implique view
+	claims = &JwtClaims{Permissions: []string{"users:update"}, Denies: []string{"users:update"}}
+	if PermissionUpdateUser.SatisfiedBy(claims) || !PermissionViewUser.SatisfiedBy(claims) {
+		t.Fatal("deny applied through implications")
+	}
+}
+
+// TestUserPermissionTenantScope garante que uma concessão feita no escopo de
+// um tenant não vale nos demais, e que um tenant não altera usuários de outro.
+func TestUserPermissionTenantScope(t *testing.T) {
+	a := newTestApp(t)
+	admin := a.superUser()
+	bob := a.createUser("bob")
+	outsider := a.createUser("outsider")
+	acme := a.createTenant("Acme", admin, bob)
+	a.createTenant("Globex", admin, bob, outsider)
+	view := a.permission(PermissionViewUser)
+
+	access, _ := a.login(testSuperUser, testSuperPass)
+	status, data, raw := a.do("POST", fmt.Sprintf("/auth/tenants/%d/switch", acme.ID), access, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("switch: %d %s", status, raw)
+	}
+	acmeAdmin := data["access_token"].(string)
+
+	if status, _, raw := a.do("PUT", fmt.Sprintf("/users/%d/permissions", bob.ID), acmeAdmin, map[string]any{"permission_id": view.ID, "effect": "allow"}); status != fiber.StatusOK {
+		t.Fatalf("grant: %d %s", status, raw)
+	}
+	if status, _, raw := a.do("PUT", fmt.Sprintf("/users/%d/permissions", outsider.ID), acmeAdmin, map[string]any{"permission_id": view.ID, "effect": "allow"}); status != fiber.StatusBadRequest {
+		t.Fatalf("grant to non-member: %d %s", status, raw)
+	}
+
+	// bob entra no primeiro tenant (Acme), onde tem a permissão
+	bobAcme, _ := a.login("bob", testPassword)
+	if _, data, raw := a.do("GET", "/auth/me", bobAcme, nil); fmt.Sprint(data["permissions"]) != "[users:view]" {
+		t.Fatalf("acme permissions: %s", raw)
+	}
+	status, data, raw = a.do("POST", fmt.Sprintf("/auth/tenants/%d/switch", acme.ID+1), bobAcme, nil)
+	if status != fiber.StatusOK {
+		t.Fatalf("switch: %d %s", status, raw)
+	}
+	if _, data, raw := a.do("GET", "/auth/me", data["access_token"].(string), nil); fmt.Sprint(data["permissions"]) != "[]" {
+		t.Fatalf("grant leaked to another tenant: %s", raw)
+	}
+}
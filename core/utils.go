@@ -3,13 +3,13 @@ package core
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
+
 	"unicode"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -94,13 +94,25 @@ func ValidateAppConfig(config *AppConfig) error {
 	if config.App == nil || config.GormStore == nil {
 		return fmt.Errorf("App or GormStore is nil")
 	}
-	if config.Jwt.JwtSecret == "" ||
-		config.Jwt.TimeZone == "" ||
+	if len(config.Jwt.SigningKeys) > 0 {
+		if err := validateJwtKeys(config.Jwt.SigningKeys); err != nil {
+			return fmt.Errorf("config jwt is invalid: %s", err.Error())
+		}
+	} else if config.Jwt.JwtSecret == "" {
+		return fmt.Errorf("config jwt is invalid")
+	}
+	if config.Jwt.TimeZone == "" ||
 		config.Jwt.AppName == "" ||
 		config.Jwt.JwtExpireAccess == 0 ||
 		config.Jwt.JwtExpireRefresh == 0 {
 		return fmt.Errorf("config jwt is invalid")
 	}
+	if err := validateOidcProviders(config.Oidc); err != nil {
+		return fmt.Errorf("config oidc is invalid: %s", err.Error())
+	}
+	if err := config.passwordPolicy().Load(); err != nil {
+		return fmt.Errorf("config password policy is invalid: %s", err.Error())
+	}
 
 	return nil
 }
@@ -113,22 +125,72 @@ func ExtractNameRolesByUser(user User) []uint {
 	return data
 }
 
+// ExtractCodePermissionsByUser retorna os códigos (sem repetição) das
+// permissões ativas das roles ativas do usuário e das concedidas diretamente,
+// exceto as negadas diretamente.
 func ExtractCodePermissionsByUser(user *User) []string {
 	var codePermissions []string
-	for _, role := range user.Roles {
+	seen := make(map[string]bool)
+	denied := ExtractDeniedCodesByUser(user)
+	add := func(permission Permission) {
+		if !permission.Active || seen[permission.Code] || IsDenied(denied, PermissionCode(permission.Code)) {
+			return
+		}
+		seen[permission.Code] = true
+		codePermissions = append(codePermissions, permission.Code)
+	}
+	// Inclui as permissões herdadas das roles pai carregadas
+	for _, role := range effectiveRoles(user.Roles) {
 		for _, permission := range role.Permissions {
-			codePermissions = append(codePermissions, permission.Code)
+			add(permission)
+		}
+	}
+	// Concessões diretas ao usuário
+	for _, override := range user.PermissionOverrides {
+		if override.Effect == PermissionAllow {
+			add(override.Permission)
 		}
 	}
 	return codePermissions
 }
 
+// sameRoles compara dois conjuntos de roles apenas pelos IDs, sem herança.
+func sameRoles(listX, listY []Role) bool {
+	ids := make(map[uint]bool)
+	for _, item := range listX {
+		ids[item.ID] = true
+	}
+	for _, item := range listY {
+		if !ids[item.ID] {
+			return false
+		}
+	}
+	return len(listX) == len(listY)
+}
+
+// ExtractRoleNamesByUser retorna os nomes das roles ativas do usuário.
+func ExtractRoleNamesByUser(user *User) []string {
+	var names []string
+	for _, role := range user.Roles {
+		if role.Active {
+			names = append(names, role.Name)
+		}
+	}
+	return names
+}
+
+// ContainsAll informa se as roles de X, considerando as herdadas, cobrem
+// todas as roles de Y. Assim quem tem manager pode delegar employee se
+// manager herda de employee.
 func ContainsAll(listX, listY []Role) bool {
-	// Criar um mapa para os itens de X
+	// Criar um mapa para os itens de X e seus ancestrais
 	itemMap := make(map[uint]bool)
 	for _, item := range listX {
 		itemMap[item.ID] = true
 	}
+	for _, item := range effectiveRoles(listX) {
+		itemMap[item.ID] = true
+	}
 
 	// Verificar se todos os itens de Y estão no mapa de X
 	for _, item := range listY {
@@ -140,42 +202,30 @@ func ContainsAll(listX, listY []Role) bool {
 	return true // Todos os itens de Y estão em X
 }
 
+// ErrInvalidCredentials é a resposta genérica do login, usada tanto para
+// usuário inexistente quanto para senha incorreta.
+var ErrInvalidCredentials = errors.New("failed to login: username or password is incorrect")
+
+// HashPassword gera o hash com o DefaultPasswordHasher. Dentro do módulo, use
+// AppConfig.HashPassword para respeitar o hasher configurado.
 func HashPassword(password string) (string, error) {
-	// Exemplo usando bcrypt
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", fmt.Errorf("failed to hash password")
-	}
-	return string(hashedPassword), nil
+	return DefaultPasswordHasher.Hash(password)
 }
 
+// CheckPasswordHash confere a senha com qualquer formato de hash suportado
+// (bcrypt ou argon2id), identificado pelo prefixo.
 func CheckPasswordHash(password, hashedPassword string) bool {
+	if strings.HasPrefix(hashedPassword, "$argon2id$") {
+		return checkArgon2idHash(password, hashedPassword)
+	}
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
 
+// Deprecated: use AppConfig.ValidatePassword, que aplica a PasswordPolicy
+// configurada e considera os dados do usuário.
 func ValidatePassword(password string) error {
-	// Verificar se contém uma letra maiúscula
-	hasUpper := false
-	hasSymbol := false
-
-	for _, r := range password {
-		if unicode.IsUpper(r) {
-			hasUpper = true
-		}
-		if unicode.IsSymbol(r) || unicode.IsPunct(r) { // Símbolos e pontuações
-			hasSymbol = true
-		}
-	}
-
-	if !hasUpper {
-		return fmt.Errorf("uppercase-password must contain at least one uppercase letter.")
-	}
-	if !hasSymbol {
-		return fmt.Errorf("symbol-password must contain at least one symbol.")
-	}
-
-	return nil
+	return DefaultPasswordPolicy.Validate(password, nil)
 }
 
 func Pagination[T any](page, limit uint, data *[]T) error {
@@ -194,79 +244,6 @@ func Pagination[T any](page, limit uint, data *[]T) error {
 	return nil
 }
 
-type PayloadJwt struct {
-	Token  string
-	Claims JwtClaims
-}
-type JwtClaims struct {
-	Sub         uint     `json:"sub"`
-	Exp         int      `json:"exp"`
-	Permissions []string `json:"permissions"`
-	IsSuperUser bool     `json:"isSuperUser"`
-	jwt.RegisteredClaims
-}
-
-type GenToken struct {
-	Id          uint
-	AppName     string
-	Permissions []string
-	IsSuperUser bool
-	TimeZone    string
-	JwtSecret   string
-	Ttl         time.Duration
-}
-
-func GenerateToken(gen *GenToken) (string, error) {
-	location, err := time.LoadLocation(gen.TimeZone)
-	if err != nil {
-		return "", fmt.Errorf("invalid timezone: %s", err.Error())
-	}
-	currentTime := time.Now().In(location)
-
-	accessTokenExpirationTime := currentTime.Add(gen.Ttl)
-
-	accessClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":         gen.Id,
-		"iss":         gen.AppName,
-		"permissions": gen.Permissions,
-		"isSuperUser": gen.IsSuperUser,
-		"iat":         currentTime.Unix(),
-		"exp":         accessTokenExpirationTime.Unix(),
-	})
-
-	accessToken, err := accessClaims.SignedString([]byte(gen.JwtSecret))
-	if err != nil {
-		return "", fmt.Errorf("could not sign access token string %v", err.Error())
-	}
-
-	return accessToken, nil
-}
-
-func GetJwtHeaderPayload(auth, secret string) (*PayloadJwt, error) {
-	// authHeader := ctx.Get("Authorization")
-	tokenString := strings.Replace(auth, "Bearer ", "", 1)
-
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&JwtClaims{},
-		func(t *jwt.Token) (any, error) {
-			tokenSecret := secret
-			return []byte(tokenSecret), nil
-		},
-	)
-	if err != nil || !token.Valid {
-		return nil, fmt.Errorf("invalid jwt token")
-	}
-
-	tokenDone := token.Claims.(*JwtClaims)
-	jwt := &PayloadJwt{
-		Token:  tokenString,
-		Claims: *tokenDone,
-	}
-
-	return jwt, nil
-}
-
 func RemoveInvisibleChars(input string) string {
 	var result []rune
 	for _, r := range input {
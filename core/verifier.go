@@ -0,0 +1,110 @@
+package core
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Erros retornados pelo TokenVerifier. JWTProtected usa a mensagem de cada
+// um como motivo do 401.
+var (
+	ErrTokenMissing     = errors.New("missing authorization token")
+	ErrTokenScheme      = errors.New("invalid authorization scheme")
+	ErrTokenMalformed   = errors.New("malformed token")
+	ErrTokenSignature   = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrTokenNotYetValid = errors.New("token not yet valid")
+	ErrTokenIssuer      = errors.New("invalid token issuer")
+	ErrTokenAudience    = errors.New("invalid token audience")
+	ErrTokenType        = errors.New("invalid token type")
+	ErrTokenRevoked     = errors.New("token revoked")
+	ErrTokenInvalid     = errors.New("invalid token")
+)
+
+// TokenVerifier valida tokens JWT aceitando apenas os algoritmos em Methods.
+type TokenVerifier struct {
+	Methods  []string    // algoritmos aceitos, ex.: "HS256", "RS256", "EdDSA"
+	Keyfunc  jwt.Keyfunc // resolve a chave de verificação
+	Issuer   string      // opcional, exige o claim "iss"
+	Audience string      // opcional, exige o claim "aud"
+	Leeway   time.Duration
+}
+
+// ParseBearer extrai o token de um header Authorization no formato
+// "Bearer <token>". O esquema não diferencia maiúsculas de minúsculas.
+func ParseBearer(header string) (string, error) {
+	if header == "" {
+		return "", ErrTokenMissing
+	}
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", ErrTokenScheme
+	}
+	if token == "" || strings.ContainsAny(token, " \t\r\n") {
+		return "", ErrTokenMalformed
+	}
+	return token, nil
+}
+
+// VerifyAuthorization valida o token contido no header Authorization.
+func (v *TokenVerifier) VerifyAuthorization(header string, typ TokenType) (*JwtClaims, error) {
+	token, err := ParseBearer(header)
+	if err != nil {
+		return nil, err
+	}
+	return v.Verify(token, typ)
+}
+
+// Verify valida assinatura, algoritmo, validade, issuer, audience e, se
+// informado, o tipo do token.
+func (v *TokenVerifier) Verify(tokenString string, typ TokenType) (*JwtClaims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(v.Methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(v.Leeway),
+	}
+	if v.Issuer != "" {
+		options = append(options, jwt.WithIssuer(v.Issuer))
+	}
+	if v.Audience != "" {
+		options = append(options, jwt.WithAudience(v.Audience))
+	}
+
+	claims := &JwtClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, v.Keyfunc, options...)
+	if err != nil {
+		return nil, classifyJwtError(err)
+	}
+	if !token.Valid {
+		return nil, ErrTokenInvalid
+	}
+	if typ != "" && claims.Typ != typ {
+		return nil, ErrTokenType
+	}
+	return claims, nil
+}
+
+func classifyJwtError(err error) error {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return ErrTokenMalformed
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid),
+		errors.Is(err, jwt.ErrTokenUnverifiable):
+		return ErrTokenSignature
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenNotValidYet),
+		errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return ErrTokenNotYetValid
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return ErrTokenIssuer
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return ErrTokenAudience
+	default:
+		return ErrTokenInvalid
+	}
+}
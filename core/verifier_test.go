@@ -0,0 +1,135 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testClaims são claims válidos de um access token de AppJwt{AppName: "test"}.
+func testClaims(change func(jwt.MapClaims)) jwt.MapClaims {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": 1,
+		"typ": TokenAccess,
+		"iss": "test",
+		"aud": []string{"test"},
+		"iat": now.Unix(),
+		"exp": now.Add(time.Minute).Unix(),
+	}
+	if change != nil {
+		change(claims)
+	}
+	return claims
+}
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// TestVerifierHS256 cobre a validação dos tokens assinados com o segredo
+// compartilhado: algoritmo fixo, tipo, validade, issuer e audience.
+func TestVerifierHS256(t *testing.T) {
+	secret := []byte("test-secret")
+	verifier := AppJwt{AppName: "test", JwtSecret: string(secret)}.Verifier()
+	none := signTestToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", testClaims(nil))
+
+	cases := []struct {
+		name    string
+		token   string
+		typ     TokenType
+		wantErr error
+	}{
+		{"valid", signTestToken(t, jwt.SigningMethodHS256, secret, "", testClaims(nil)), TokenAccess, nil},
+		{"any type", signTestToken(t, jwt.SigningMethodHS256, secret, "", testClaims(nil)), "", nil},
+		{"wrong type", signTestToken(t, jwt.SigningMethodHS256, secret, "", testClaims(nil)), TokenRefresh, ErrTokenType},
+		{"other hmac algorithm", signTestToken(t, jwt.SigningMethodHS384, secret, "", testClaims(nil)), TokenAccess, ErrTokenSignature},
+		{"alg none", none, TokenAccess, ErrTokenSignature},
+		{"wrong secret", signTestToken(t, jwt.SigningMethodHS256, []byte("other"), "", testClaims(nil)), TokenAccess, ErrTokenSignature},
+		{"expired", signTestToken(t, jwt.SigningMethodHS256, secret, "", testClaims(func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-time.Minute).Unix()
+		})), TokenAccess, ErrTokenExpired},
+		{"missing exp", signTestToken(t, jwt.SigningMethodHS256, secret, "", testClaims(func(c jwt.MapClaims) {
+			delete(c, "exp")
+		})), TokenAccess, ErrTokenInvalid},
+		{"issued in the future", signTestToken(t, jwt.SigningMethodHS256, secret, "", testClaims(func(c jwt.MapClaims) {
+			c["iat"] = time.Now().Add(time.Hour).Unix()
+		})), TokenAccess, ErrTokenNotYetValid},
+		{"wrong issuer", signTestToken(t, jwt.SigningMethodHS256, secret, "", testClaims(func(c jwt.MapClaims) {
+			c["iss"] = "other"
+		})), TokenAccess, ErrTokenIssuer},
+		{"wrong audience", signTestToken(t, jwt.SigningMethodHS256, secret, "", testClaims(func(c jwt.MapClaims) {
+			c["aud"] = []string{"other"}
+		})), TokenAccess, ErrTokenAudience},
+		{"malformed", "not.a.token", TokenAccess, ErrTokenMalformed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := verifier.Verify(tc.token, tc.typ)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// TestVerifierAsymmetric garante que cada chave aceita só o algoritmo do seu
+// tipo, inclusive contra a confusão RS256/HS256 com a chave pública.
+func TestVerifierAsymmetric(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRsa, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDer, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := AppJwt{
+		AppName:     "test",
+		SigningKeys: []JwtKey{{Kid: "r1", PrivateKey: rsaKey}, {Kid: "e1", PrivateKey: edKey}},
+	}.Verifier()
+
+	cases := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"rsa key", signTestToken(t, jwt.SigningMethodRS256, rsaKey, "r1", testClaims(nil)), nil},
+		{"ed25519 key", signTestToken(t, jwt.SigningMethodEdDSA, edKey, "e1", testClaims(nil)), nil},
+		{"algorithm of another key", signTestToken(t, jwt.SigningMethodRS256, rsaKey, "e1", testClaims(nil)), ErrTokenSignature},
+		{"hmac with public key", signTestToken(t, jwt.SigningMethodHS256, publicDer, "r1", testClaims(nil)), ErrTokenSignature},
+		{"unknown kid", signTestToken(t, jwt.SigningMethodRS256, rsaKey, "r2", testClaims(nil)), ErrTokenSignature},
+		{"foreign rsa key", signTestToken(t, jwt.SigningMethodRS256, otherRsa, "r1", testClaims(nil)), ErrTokenSignature},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := verifier.Verify(tc.token, TokenAccess)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
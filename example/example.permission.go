@@ -5,7 +5,7 @@ import (
 )
 
 const (
-	PermissionExampleCreate core.PermissionCode = "create_example"
-	PermissionExampleView   core.PermissionCode = "view_example"
-	PermissionExampleUpdate core.PermissionCode = "update_example"
+	PermissionExampleCreate core.PermissionCode = "example:create"
+	PermissionExampleView   core.PermissionCode = "example:view"
+	PermissionExampleUpdate core.PermissionCode = "example:update"
 )
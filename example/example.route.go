@@ -15,7 +15,7 @@ func (r *Router) Ws(router fiber.Router) {
 		"/:id",
 		core.IsWsMiddleware(),
 		core.ValidationMiddleware(&WsConn{}),
-		core.JWTProtected(r.Jwt.JwtSecret),
+		r.JWTProtected(),
 		websocket.New(r.Controller.websocketHandler),
 	)
 }